@@ -156,6 +156,13 @@ elif [ -f "$SCRIPT_DIR/config.yaml" ]; then
     mkdir -p "$SCRIPT_DIR/config"
     mv "$SCRIPT_DIR/config.yaml" "$SCRIPT_DIR/config/config.yaml"
     echo "⚠️ DO NOT DELETE/MOVE THE config FOLDER OR ITS CONTENTS!"
+elif [ -e /dev/tty ]; then
+    echo "⚠️ config.yaml not found, answer the following questions to create one..."
+    # The script itself may be piped to bash, so read the answers from the terminal
+    if ! (cd "$SCRIPT_DIR" && "$SCRIPT_DIR/node-automated-deployer" init < /dev/tty); then
+        echo "❌ Failed to create config.yaml, please create a config.yaml file or generate one at https://explorer.rss3.io."
+        exit 1
+    fi
 else
     echo "⚠️ config.yaml not found, please create a config.yaml file or generate one at https://explorer.rss3.io."
     exit 1
@@ -176,6 +183,12 @@ else
 fi
 
 export NODE_VERSION
+
+# AlloyDB Omni is only published for amd64, use PostgreSQL on arm64 unless told otherwise
+if [ -z "$DATABASE_BACKEND" ] && { [ "$ARCH" = "arm64" ] || [ "$ARCH" = "aarch64" ]; }; then
+    DATABASE_BACKEND="postgres"
+fi
+export DATABASE_BACKEND
 echo "🚀 Running the deployer..."
 "$SCRIPT_DIR/node-automated-deployer" > "$SCRIPT_DIR/docker-compose.yaml"
 
@@ -185,13 +198,19 @@ if [ -f "$SCRIPT_DIR/docker-compose.yaml" ]; then
     (cd "$SCRIPT_DIR" && $COMPOSE_CMD up -d)
 
     # Check if Docker Compose started successfully
-    if [ $? -eq 0 ]; then
-        echo "✅ Deployment process completed successfully."
-        echo "🎉 Welcome to the RSS3 Network!"
-    else
+    if [ $? -ne 0 ]; then
         echo "❌ Failed to start Docker Compose."
         exit 1
     fi
+
+    echo "🔍 Verifying the node..."
+    if ! "$SCRIPT_DIR/node-automated-deployer" verify --compose-file "$SCRIPT_DIR/docker-compose.yaml"; then
+        echo "❌ The node services started but the verification failed, check them with: $COMPOSE_CMD logs"
+        exit 1
+    fi
+
+    echo "✅ Deployment process completed successfully."
+    echo "🎉 Welcome to the RSS3 Network!"
 else
     echo "❌ Failed to create docker-compose.yaml."
     exit 1
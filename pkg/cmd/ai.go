@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/configfile"
+	"github.com/rss3-network/node-automated-deployer/pkg/health"
+	"github.com/rss3-network/node/v2/config"
+)
+
+var aiMode = aiModeAuto
+
+const (
+	aiModeAuto     = "auto"
+	aiModeExternal = "external"
+	aiModeLocal    = "local"
+	aiModeOff      = "off"
+)
+
+// decideAIComponent decides where the node services find the AI component according to --ai, printing why to stderr.
+// In auto mode a configured endpoint is used if healthy, otherwise a local agentdata service is deployed,
+// in external mode an endpoint that isn't healthy is an error rather than replaced.
+// An auto decision locked for the same endpoint is reused without probing it again.
+func decideAIComponent(cfg *config.File, endpoint, file string, plan *deploymentPlan) (compose.AIComponent, error) {
+	configured := cfg.Component != nil && cfg.Component.AI != nil
+
+	switch aiMode {
+	case aiModeOff:
+		logAIDecision(compose.AIComponentOff, "disabled by --ai=off")
+
+		return compose.AIComponentOff, nil
+	case aiModeLocal:
+		if !configured {
+			return compose.AIComponentOff, fmt.Errorf("an ai component is required in %s when using --ai=local", file)
+		}
+
+		logAIDecision(compose.AIComponentLocal, "forced by --ai=local")
+
+		return compose.AIComponentLocal, nil
+	case aiModeExternal:
+		if endpoint == "" {
+			return compose.AIComponentOff, fmt.Errorf("an ai component endpoint is required in %s when using --ai=external", file)
+		}
+
+		result := checkAIEndpointHealth(endpoint)
+		if !result.Healthy {
+			return compose.AIComponentOff, fmt.Errorf("ai endpoint %s is %s, %w", result.Target, aiEndpointState(result), result.Err())
+		}
+
+		logAIDecision(compose.AIComponentExternal, fmt.Sprintf("endpoint %s is healthy", result.Target))
+
+		return compose.AIComponentExternal, nil
+	case aiModeAuto:
+		if !configured {
+			logAIDecision(compose.AIComponentOff, "no ai component in "+file)
+
+			return compose.AIComponentOff, nil
+		}
+
+		if endpoint == "" {
+			logAIDecision(compose.AIComponentLocal, "no ai component endpoint in "+file)
+
+			return compose.AIComponentLocal, nil
+		}
+
+		if component, ok := plan.lockedAI(); ok {
+			logAIDecision(component, fmt.Sprintf("locked in %s, pass --refresh to probe %s again", plan.file, endpoint))
+
+			return component, nil
+		}
+
+		result := checkAIEndpointHealth(endpoint)
+		if !result.Healthy {
+			logAIDecision(compose.AIComponentLocal, fmt.Sprintf("endpoint %s is %s (%v), pass --ai=external to fail instead", result.Target, aiEndpointState(result), result.Err()))
+
+			return compose.AIComponentLocal, nil
+		}
+
+		logAIDecision(compose.AIComponentExternal, fmt.Sprintf("endpoint %s is healthy", result.Target))
+
+		return compose.AIComponentExternal, nil
+	default:
+		return compose.AIComponentOff, fmt.Errorf("unsupported ai mode %s, must be one of %s, %s, %s, %s", aiMode, aiModeAuto, aiModeExternal, aiModeLocal, aiModeOff)
+	}
+}
+
+func logAIDecision(component compose.AIComponent, reason string) {
+	fmt.Fprintf(os.Stderr, "AI component: %s, %s\n", component, reason)
+}
+
+// aiEndpointState tells an endpoint answering with an error status from one that can't be reached
+func aiEndpointState(result *health.Result) string {
+	var statusError *health.StatusError
+	if errors.As(result.Err(), &statusError) {
+		return "unhealthy"
+	}
+
+	return "unreachable"
+}
+
+// checkAIEndpointHealth verifies if the provided AI endpoint is responsive and operational.
+// It performs multiple attempts to account for potential network issues.
+func checkAIEndpointHealth(endpoint string) *health.Result {
+	prober := health.NewHTTPProber(normalizeEndpointURL(endpoint)+"api/v1/health", nil)
+
+	return health.Check(context.Background(), prober,
+		health.WithAttempts(3),
+		health.WithAttemptTimeout(5*time.Second),
+		health.WithBackoff(health.Backoff{Initial: time.Second}),
+	)
+}
+
+// withoutAIComponent returns the config content without its ai component, for the node services when it is off
+func withoutAIComponent(content []byte) ([]byte, bool, error) {
+	configFile, err := configfile.Parse(content)
+	if err != nil {
+		return nil, false, err
+	}
+
+	removed, err := configFile.Unset([]string{"component", "ai"})
+	if err != nil {
+		return nil, false, fmt.Errorf("remove ai component, %w", err)
+	}
+
+	return configFile.Bytes(), removed, nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&aiMode, "ai", aiMode, "Where the node finds the AI component, external, local, auto or off")
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/helm"
+	"github.com/rss3-network/node-automated-deployer/pkg/secrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	chartDir     = "chart"
+	chartVersion = ""
+)
+
+var chartCmd = &cobra.Command{
+	Use:   "chart",
+	Short: "Generate a Helm chart of the node deployment.",
+	Long: `Generate a Helm chart of the node deployment.
+The chart exposes the node version, the workers, the AlloyDB credentials and the local agentdata service as values,
+so the deployment can be upgraded with helm upgrade instead of regenerating the manifests.
+The config file is left untouched, its patched copy is only embedded into the chart.
+The chart uses the credentials of the secrets file, deploy the node once to generate them.`,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		// The chart takes over the data of the deployment, throwaway credentials would lock it out
+		persisted, err := secrets.Load(secretsFile)
+		if err != nil {
+			return err
+		}
+
+		if persisted.PostgresPassword == "" || persisted.RedisPassword == "" {
+			return fmt.Errorf("no credentials in %s, deploy the node once to generate them", secretsFile)
+		}
+
+		// Only the chart is written, the config file is patched in memory like in dry run mode
+		d, err := prepareDeployment(file, true)
+		if err != nil {
+			return err
+		}
+
+		// Always render the agentdata service, whether it is enabled is decided by the values
+		useLocalAgentdata := d.aiComponent == compose.AIComponentLocal
+		d.aiComponent = compose.AIComponentLocal
+
+		chart, err := helm.NewChart(d.newCompose(), d.configFile,
+			helm.WithChartVersion(chartVersion),
+			helm.WithNodeVersion(d.version),
+			helm.WithWorkers(d.cfg.Component.Decentralized),
+			helm.WithWorkers(d.cfg.Component.Federated),
+			helm.WithAlloyDBPassword(d.secrets.PostgresPassword),
+			helm.WithAgentdata(useLocalAgentdata),
+		)
+		if err != nil {
+			return fmt.Errorf("generate chart, %w", err)
+		}
+
+		if err := chart.Write(chartDir); err != nil {
+			return err
+		}
+
+		fmt.Printf("Helm chart written to %s\n", chartDir)
+
+		return nil
+	},
+}
+
+func init() {
+	chartCmd.Flags().StringVarP(&chartDir, "dir", "d", chartDir, "Directory to write the chart to")
+	chartCmd.Flags().StringVar(&chartVersion, "chart-version", chartVersion, "Version of the generated chart (default: 0.1.0)")
+
+	rootCmd.AddCommand(chartCmd)
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/configfile"
+	"github.com/rss3-network/node-automated-deployer/pkg/secrets"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var configShowSecrets = false
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read and edit values of the config file.",
+	Long: `Read and edit values of the config file using dotted paths,
+e.g. component.ai.endpoint or component.decentralized[2].parameters.
+Comments and formatting of the config file are preserved.`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <path>",
+	Short: "Print the value at the path.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		configFile, path, err := loadConfigFilePath(args[0])
+		if err != nil {
+			return err
+		}
+
+		node := configFile.Get(path)
+		if node == nil {
+			return fmt.Errorf("%s not found in %s", args[0], configFile.Path())
+		}
+
+		output := node.Value + "\n"
+
+		if node.Kind != yaml.ScalarNode {
+			content, err := yaml.Marshal(node)
+			if err != nil {
+				return err
+			}
+
+			output = string(content)
+		}
+
+		if !configShowSecrets {
+			deploymentSecrets, err := secrets.Load(secretsFile)
+			if err != nil {
+				return err
+			}
+
+			output = newConfigMasker(deploymentSecrets.Values(), configFile.Root()).Mask(output)
+		}
+
+		fmt.Print(output)
+
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <path> <value>",
+	Short: "Set the value at the path, creating the missing sections.",
+	Long: `Set the value at the path, creating the missing sections.
+The value is parsed as YAML, so numbers and booleans keep their type and {key: value} sets a mapping.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(_ *cobra.Command, args []string) error {
+		configFile, path, err := loadConfigFilePath(args[0])
+		if err != nil {
+			return err
+		}
+
+		var document yaml.Node
+		if err := yaml.Unmarshal([]byte(args[1]), &document); err != nil {
+			return fmt.Errorf("parse value %s, %w", args[1], err)
+		}
+
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+		if len(document.Content) > 0 {
+			value = document.Content[0]
+		}
+
+		clearFlowStyle(value)
+
+		if err := configFile.Set(path, value); err != nil {
+			return err
+		}
+
+		return configFile.Save()
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <path>",
+	Short: "Remove the value at the path.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		configFile, path, err := loadConfigFilePath(args[0])
+		if err != nil {
+			return err
+		}
+
+		found, err := configFile.Unset(path)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return fmt.Errorf("%s not found in %s", args[0], configFile.Path())
+		}
+
+		return configFile.Save()
+	},
+}
+
+// loadConfigFilePath loads the config file and parses a dotted path
+func loadConfigFilePath(dottedPath string) (*configfile.File, []string, error) {
+	path, err := configfile.ParsePath(dottedPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	discovered, err := discoverConfigFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configFile, err := configfile.Load(discovered)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return configFile, path, nil
+}
+
+// clearFlowStyle renders values given inline, e.g. {port: 8181}, in block style like the rest of the file
+func clearFlowStyle(node *yaml.Node) {
+	node.Style &^= yaml.FlowStyle
+
+	for _, child := range node.Content {
+		clearFlowStyle(child)
+	}
+}
+
+func init() {
+	configGetCmd.Flags().BoolVar(&configShowSecrets, "show-secrets", configShowSecrets, "Print credentials such as access tokens and passwords instead of masking them")
+
+	configCmd.AddCommand(configGetCmd, configSetCmd, configUnsetCmd)
+
+	rootCmd.AddCommand(configCmd)
+}
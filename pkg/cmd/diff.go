@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/spf13/cobra"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+var diffFormat = formatText
+
+// errDifferencesFound makes the diff command exit with a non-zero status
+var errDifferencesFound = errors.New("differences found")
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old docker-compose.yaml> <new docker-compose.yaml>",
+	Short: "Show the per service changes between two docker-compose files.",
+	Long: `Show the per service changes between two docker-compose files.
+Image, command, ports, environment, volumes, depends_on and healthcheck changes are reported,
+environment values are masked. Exits with a non-zero status when the files differ.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diffFormat != formatText && diffFormat != formatJSON {
+			return fmt.Errorf("unsupported format %s, must be one of %s, %s", diffFormat, formatText, formatJSON)
+		}
+
+		old, err := compose.Load(args[0])
+		if err != nil {
+			return err
+		}
+
+		next, err := compose.Load(args[1])
+		if err != nil {
+			return err
+		}
+
+		diffs := compose.Diff(old, next)
+
+		if diffFormat == formatJSON {
+			if diffs == nil {
+				diffs = []compose.ServiceDiff{}
+			}
+
+			e := json.NewEncoder(os.Stdout)
+			e.SetIndent("", "  ")
+
+			if err := e.Encode(diffs); err != nil {
+				return err
+			}
+		} else {
+			printComposeDiff(os.Stdout, diffs)
+		}
+
+		if len(diffs) > 0 {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+
+			return errDifferencesFound
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffFormat, "format", diffFormat, "Output format, text or json")
+
+	rootCmd.AddCommand(diffCmd)
+}
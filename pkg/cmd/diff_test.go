@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//nolint:paralleltest // the commands share their flags through the root command
+func TestDiffExitStatus(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"old.yaml":     "services:\n  core:\n    image: rss3/node:v2.0.0\n",
+		"same.yaml":    "services:\n  core:\n    image: rss3/node:v2.0.0\n",
+		"changed.yaml": "services:\n  core:\n    image: rss3/node:v2.0.1\n",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testcases := []struct {
+		name     string
+		file     string
+		format   string
+		expected error
+	}{
+		{name: "same", file: "same.yaml", format: formatText},
+		{name: "changed", file: "changed.yaml", format: formatText, expected: errDifferencesFound},
+		{name: "same json", file: "same.yaml", format: formatJSON},
+		{name: "changed json", file: "changed.yaml", format: formatJSON, expected: errDifferencesFound},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			// The error makes main exit with a non-zero status, failing a CI job
+			rootCmd.SetArgs([]string{"diff", "--format", testcase.format, filepath.Join(dir, "old.yaml"), filepath.Join(dir, testcase.file)})
+
+			if err := rootCmd.Execute(); !errors.Is(err, testcase.expected) {
+				t.Errorf("expected %v, got %v", testcase.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,474 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rss3-network/node/v2/schema/worker/decentralized"
+	"github.com/rss3-network/node/v2/schema/worker/federated"
+	"github.com/rss3-network/node/v2/schema/worker/rss"
+	"github.com/rss3-network/protocol-go/schema/network"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	initEvmAddress       = ""
+	initSignature        = ""
+	initServerEndpoint   = ""
+	initDecentralized    []string
+	initFederated        []string
+	initEndpoints        map[string]string
+	initRSSHub           = ""
+	initAIEndpoint       = ""
+	initOpenAIAPIKeyFile = ""
+	initOllamaHost       = ""
+	initNonInteractive   = false
+	initForce            = false
+)
+
+// openAIAPIKeyEnv is the environment variable the OpenAI API key is read from, it is never given as a flag
+// so it doesn't show up in the shell history or the process list
+const openAIAPIKeyEnv = "OPENAI_API_KEY"
+
+// initConfigFile is the subset of config.File written by the init command, in the order of the node's example config
+type initConfigFile struct {
+	Environment string                  `yaml:"environment"`
+	Endpoints   map[string]initEndpoint `yaml:"endpoints,omitempty"`
+	Discovery   initDiscovery           `yaml:"discovery"`
+	Database    initDatabase            `yaml:"database"`
+	Redis       initRedis               `yaml:"redis"`
+	Component   initComponent           `yaml:"component"`
+}
+
+type initEndpoint struct {
+	URL string `yaml:"url"`
+}
+
+type initDiscovery struct {
+	Operator initOperator `yaml:"operator"`
+	Server   initServer   `yaml:"server"`
+}
+
+type initOperator struct {
+	EvmAddress string `yaml:"evm_address"`
+	Signature  string `yaml:"signature,omitempty"`
+}
+
+type initServer struct {
+	Endpoint    string `yaml:"endpoint,omitempty"`
+	AccessToken string `yaml:"access_token"`
+}
+
+type initDatabase struct {
+	CoveragePeriod int    `yaml:"coverage_period"`
+	URI            string `yaml:"uri"`
+}
+
+type initRedis struct {
+	Endpoint string `yaml:"endpoint"`
+}
+
+type initComponent struct {
+	RSS           *initModule  `yaml:"rss,omitempty"`
+	Decentralized []initModule `yaml:"decentralized,omitempty"`
+	Federated     []initModule `yaml:"federated,omitempty"`
+	AI            *initModule  `yaml:"ai,omitempty"`
+}
+
+type initModule struct {
+	ID         string         `yaml:"id"`
+	Network    string         `yaml:"network,omitempty"`
+	Worker     string         `yaml:"worker,omitempty"`
+	Endpoint   string         `yaml:"endpoint,omitempty"`
+	Parameters map[string]any `yaml:"parameters,omitempty"`
+}
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a new config file for the node.",
+	Long: `Create a new config file for the node, asking for the operator, the workers to enable
+and the RSS and AI components. Every answer can be given as a flag instead, use --non-interactive
+to fail on missing values rather than asking for them. The OpenAI API key is read from $OPENAI_API_KEY
+or --openai-api-key-file, and asked for without echo otherwise.
+The config file is written to config/config.yaml, where the other commands look for it.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		target := initConfigFilePath(file)
+
+		if _, err := os.Stat(target); err == nil && !initForce {
+			return fmt.Errorf("config file %s already exists, use --force to overwrite it", target)
+		}
+
+		p := &prompter{
+			reader:         bufio.NewReader(cmd.InOrStdin()),
+			writer:         cmd.ErrOrStderr(),
+			nonInteractive: initNonInteractive,
+		}
+
+		// Secrets typed on a terminal are not echoed
+		if stdin, ok := cmd.InOrStdin().(*os.File); ok && term.IsTerminal(int(stdin.Fd())) {
+			p.readPassword = func() ([]byte, error) {
+				return term.ReadPassword(int(stdin.Fd()))
+			}
+		}
+
+		configFile, err := buildInitConfigFile(p)
+		if err != nil {
+			return err
+		}
+
+		var b bytes.Buffer
+
+		e := yaml.NewEncoder(&b)
+		e.SetIndent(2)
+
+		if err := e.Encode(configFile); err != nil {
+			return fmt.Errorf("encode config file, %w", err)
+		}
+
+		// Make sure the node accepts the generated config file
+		if _, err := setupConfig(b.Bytes()); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("create config directory, %w", err)
+		}
+
+		if err := os.WriteFile(target, b.Bytes(), 0600); err != nil {
+			return fmt.Errorf("write config file, %w", err)
+		}
+
+		fmt.Fprintf(cmd.ErrOrStderr(), "config file written to %s\n", target)
+
+		return nil
+	},
+}
+
+// initConfigFilePath returns where the config file is written, a bare file name is placed in the config directory
+func initConfigFilePath(file string) string {
+	if filepath.Dir(file) == "." {
+		return filepath.Join("config", file)
+	}
+
+	return file
+}
+
+// buildInitConfigFile fills the config file from the flags, asking for the missing values
+func buildInitConfigFile(p *prompter) (*initConfigFile, error) {
+	configFile := initConfigFile{
+		Environment: "production",
+		Endpoints:   make(map[string]initEndpoint),
+		Database: initDatabase{
+			CoveragePeriod: 3,
+			URI:            "postgres://postgres@rss3_node_alloydb:5432/postgres",
+		},
+		Redis: initRedis{
+			Endpoint: "rss3_node_redis:6379",
+		},
+	}
+
+	evmAddress, err := p.ask("Operator EVM address", initEvmAddress, true, func(value string) error {
+		if !common.IsHexAddress(value) {
+			return fmt.Errorf("%s is not an EVM address", value)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := p.ask("Operator signature (optional)", initSignature, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	serverEndpoint, err := p.ask("Public endpoint of the node (optional)", initServerEndpoint, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	configFile.Discovery = initDiscovery{
+		Operator: initOperator{EvmAddress: evmAddress, Signature: signature},
+		Server:   initServer{Endpoint: serverEndpoint, AccessToken: "sk-" + randomString(32)},
+	}
+
+	if configFile.Component.Decentralized, err = askWorkers(p, "decentralized", initDecentralized, decentralized.WorkerStrings(), func(value string) error {
+		_, err := decentralized.WorkerString(value)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	if configFile.Component.Federated, err = askWorkers(p, "federated", initFederated, federated.WorkerStrings(), func(value string) error {
+		_, err := federated.WorkerString(value)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	// Workers of the same network share one endpoint, referenced by the network name
+	for _, workers := range [][]initModule{configFile.Component.Decentralized, configFile.Component.Federated} {
+		for _, worker := range workers {
+			if _, ok := configFile.Endpoints[worker.Network]; ok {
+				continue
+			}
+
+			endpoint, err := p.ask(fmt.Sprintf("Endpoint of the %s network", worker.Network), initEndpoints[worker.Network], true, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			configFile.Endpoints[worker.Network] = initEndpoint{URL: endpoint}
+		}
+	}
+
+	rssHub, err := p.ask("RSSHub endpoint (optional)", initRSSHub, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if rssHub != "" {
+		configFile.Component.RSS = &initModule{
+			ID:       network.RSSHub.String() + "-" + rss.Core.String(),
+			Network:  network.RSSHub.String(),
+			Worker:   rss.Core.String(),
+			Endpoint: rssHub,
+		}
+	}
+
+	if configFile.Component.AI, err = askAIComponent(p); err != nil {
+		return nil, err
+	}
+
+	return &configFile, nil
+}
+
+// askWorkers returns the workers given as network:worker pairs
+func askWorkers(p *prompter, section string, defaultValue []string, workers []string, validate func(string) error) ([]initModule, error) {
+	if p.nonInteractive || len(defaultValue) > 0 {
+		return parseWorkers(section, defaultValue, validate)
+	}
+
+	fmt.Fprintf(p.writer, "Available %s workers: %s\n", section, strings.Join(workers, ", "))
+
+	for {
+		answer, err := p.ask(fmt.Sprintf("Enabled %s workers as network:worker, comma separated (optional)", section), "", false, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		modules, err := parseWorkers(section, strings.Split(answer, ","), validate)
+		if err == nil {
+			return modules, nil
+		}
+
+		fmt.Fprintln(p.writer, err)
+	}
+}
+
+func parseWorkers(section string, values []string, validate func(string) error) ([]initModule, error) {
+	var modules []initModule
+
+	ids := make(map[string]bool)
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		networkName, workerName, ok := strings.Cut(value, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s worker %s, must be network:worker", section, value)
+		}
+
+		if _, err := network.NetworkString(networkName); err != nil {
+			return nil, fmt.Errorf("unknown network %s", networkName)
+		}
+
+		if err := validate(workerName); err != nil {
+			return nil, fmt.Errorf("unknown %s worker %s", section, workerName)
+		}
+
+		id := networkName + "-" + workerName
+		if ids[id] {
+			continue
+		}
+
+		ids[id] = true
+
+		modules = append(modules, initModule{
+			ID:       id,
+			Network:  networkName,
+			Worker:   workerName,
+			Endpoint: networkName,
+		})
+	}
+
+	return modules, nil
+}
+
+// askAIComponent returns the AI component, either using a remote agentdata endpoint
+// or the parameters of a local agentdata service
+func askAIComponent(p *prompter) (*initModule, error) {
+	endpoint, err := p.ask("AI component endpoint, leave empty to run agentdata locally or disable it (optional)", initAIEndpoint, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	openAIAPIKey, err := readOpenAIAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if openAIAPIKey, err = p.askSecret("OpenAI API key for a local agentdata service (optional)", openAIAPIKey); err != nil {
+		return nil, err
+	}
+
+	ollamaHost := initOllamaHost
+
+	if openAIAPIKey == "" {
+		if ollamaHost, err = p.ask("Ollama host for a local agentdata service (optional)", initOllamaHost, false, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if endpoint == "" && openAIAPIKey == "" && ollamaHost == "" {
+		return nil, nil
+	}
+
+	module := &initModule{
+		ID:         "ai",
+		Endpoint:   endpoint,
+		Parameters: make(map[string]any),
+	}
+
+	if openAIAPIKey != "" {
+		module.Parameters["openai_api_key"] = openAIAPIKey
+	}
+
+	if ollamaHost != "" {
+		module.Parameters["ollama_host"] = ollamaHost
+	}
+
+	return module, nil
+}
+
+// readOpenAIAPIKey returns the OpenAI API key of --openai-api-key-file, or of the environment
+func readOpenAIAPIKey() (string, error) {
+	if initOpenAIAPIKeyFile == "" {
+		return os.Getenv(openAIAPIKeyEnv), nil
+	}
+
+	content, err := os.ReadFile(initOpenAIAPIKeyFile)
+	if err != nil {
+		return "", fmt.Errorf("read openai api key, %w", err)
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// prompter asks for the values not given as flags
+type prompter struct {
+	reader         *bufio.Reader
+	writer         io.Writer
+	nonInteractive bool
+	// readPassword reads a secret without echoing it, secrets are read like the other answers if it is nil
+	readPassword func() ([]byte, error)
+}
+
+// ask returns the value if set, otherwise it asks for it until a valid answer is given
+func (p *prompter) ask(label, value string, required bool, validate func(string) error) (string, error) {
+	check := func(value string) error {
+		if value == "" {
+			if required {
+				return fmt.Errorf("%s is required", label)
+			}
+
+			return nil
+		}
+
+		if validate != nil {
+			return validate(value)
+		}
+
+		return nil
+	}
+
+	if value != "" || p.nonInteractive {
+		return value, check(value)
+	}
+
+	for {
+		fmt.Fprintf(p.writer, "%s: ", label)
+
+		line, err := p.reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("read answer, %w", err)
+		}
+
+		answer := strings.TrimSpace(line)
+
+		checkErr := check(answer)
+		if checkErr == nil {
+			return answer, nil
+		}
+
+		if errors.Is(err, io.EOF) {
+			return "", checkErr
+		}
+
+		fmt.Fprintln(p.writer, checkErr)
+	}
+}
+
+// askSecret returns the value if set, otherwise it asks for an optional secret without echoing the answer
+func (p *prompter) askSecret(label, value string) (string, error) {
+	if value != "" || p.nonInteractive {
+		return value, nil
+	}
+
+	if p.readPassword == nil {
+		return p.ask(label, value, false, nil)
+	}
+
+	fmt.Fprintf(p.writer, "%s: ", label)
+
+	answer, err := p.readPassword()
+
+	// The newline typed by the user isn't echoed either
+	fmt.Fprintln(p.writer)
+
+	if err != nil {
+		return "", fmt.Errorf("read answer, %w", err)
+	}
+
+	return strings.TrimSpace(string(answer)), nil
+}
+
+func init() {
+	initCmd.Flags().StringVar(&initEvmAddress, "evm-address", initEvmAddress, "EVM address of the node operator")
+	initCmd.Flags().StringVar(&initSignature, "signature", initSignature, "Signature of the node operator")
+	initCmd.Flags().StringVar(&initServerEndpoint, "server-endpoint", initServerEndpoint, "Public endpoint of the node")
+	initCmd.Flags().StringSliceVar(&initDecentralized, "decentralized", initDecentralized, "Decentralized workers to enable as network:worker, e.g. ethereum:core")
+	initCmd.Flags().StringSliceVar(&initFederated, "federated", initFederated, "Federated workers to enable as network:worker, e.g. mastodon:mastodon")
+	initCmd.Flags().StringToStringVar(&initEndpoints, "endpoint", initEndpoints, "Endpoint of a network as network=url, e.g. ethereum=https://...")
+	initCmd.Flags().StringVar(&initRSSHub, "rsshub", initRSSHub, "RSSHub endpoint of the RSS component")
+	initCmd.Flags().StringVar(&initAIEndpoint, "ai-endpoint", initAIEndpoint, "Endpoint of a remote agentdata service")
+	initCmd.Flags().StringVar(&initOpenAIAPIKeyFile, "openai-api-key-file", initOpenAIAPIKeyFile, "File holding the OpenAI API key of a local agentdata service, $OPENAI_API_KEY is used otherwise")
+	initCmd.Flags().StringVar(&initOllamaHost, "ollama-host", initOllamaHost, "Ollama host of a local agentdata service")
+	initCmd.Flags().BoolVar(&initNonInteractive, "non-interactive", initNonInteractive, "Fail on missing values instead of asking for them")
+	initCmd.Flags().BoolVar(&initForce, "force", initForce, "Overwrite an existing config file")
+
+	rootCmd.AddCommand(initCmd)
+}
@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rss3-network/node/v2/schema/worker/decentralized"
+)
+
+const testEvmAddress = "0x0000000000000000000000000000000000000001"
+
+func TestParseWorkers(t *testing.T) {
+	t.Parallel()
+
+	validate := func(value string) error {
+		_, err := decentralized.WorkerString(value)
+		return err
+	}
+
+	testcases := []struct {
+		name     string
+		values   []string
+		expected []initModule
+		err      string
+	}{
+		{
+			name:   "workers",
+			values: []string{" ethereum:core", "", "ethereum:uniswap", "ethereum:core"},
+			expected: []initModule{
+				{ID: "ethereum-core", Network: "ethereum", Worker: "core", Endpoint: "ethereum"},
+				{ID: "ethereum-uniswap", Network: "ethereum", Worker: "uniswap", Endpoint: "ethereum"},
+			},
+		},
+		{name: "no workers", values: []string{""}},
+		{name: "missing worker", values: []string{"ethereum"}, err: "invalid decentralized worker ethereum, must be network:worker"},
+		{name: "unknown network", values: []string{"ethereal:core"}, err: "unknown network ethereal"},
+		{name: "unknown worker", values: []string{"ethereum:miner"}, err: "unknown decentralized worker miner"},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			modules, err := parseWorkers("decentralized", testcase.values, validate)
+			if testcase.err != "" {
+				if err == nil || err.Error() != testcase.err {
+					t.Fatalf("expected the error %q, got %v", testcase.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(modules, testcase.expected) {
+				t.Errorf("expected %+v, got %+v", testcase.expected, modules)
+			}
+		})
+	}
+}
+
+func TestPrompterAsk(t *testing.T) {
+	t.Parallel()
+
+	validate := func(value string) error {
+		if value != "valid" {
+			return os.ErrInvalid
+		}
+
+		return nil
+	}
+
+	testcases := []struct {
+		name           string
+		input          string
+		value          string
+		required       bool
+		nonInteractive bool
+		expected       string
+		err            bool
+	}{
+		{name: "given value", value: "valid", expected: "valid"},
+		{name: "answer", input: "valid\n", expected: "valid"},
+		{name: "asked again until valid", input: "invalid\n\nvalid\n", required: true, expected: "valid"},
+		{name: "optional", input: "\n"},
+		{name: "required at the end of the input", input: "", required: true, err: true},
+		{name: "invalid at the end of the input", input: "invalid", err: true},
+		{name: "non interactive", input: "valid\n", nonInteractive: true},
+		{name: "required and non interactive", input: "valid\n", required: true, nonInteractive: true, err: true},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := &prompter{
+				reader:         bufio.NewReader(strings.NewReader(testcase.input)),
+				writer:         &bytes.Buffer{},
+				nonInteractive: testcase.nonInteractive,
+			}
+
+			answer, err := p.ask("Value", testcase.value, testcase.required, validate)
+			if testcase.err != (err != nil) {
+				t.Fatalf("expected an error: %t, got %v", testcase.err, err)
+			}
+
+			if answer != testcase.expected {
+				t.Errorf("expected %q, got %q", testcase.expected, answer)
+			}
+		})
+	}
+}
+
+func TestPrompterAskSecret(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	p := &prompter{
+		reader: bufio.NewReader(strings.NewReader("echoed\n")),
+		writer: &output,
+		readPassword: func() ([]byte, error) {
+			return []byte("sk-secret\n"), nil
+		},
+	}
+
+	// The secret is read without echo rather than from the answers
+	answer, err := p.askSecret("OpenAI API key", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if answer != "sk-secret" {
+		t.Errorf("expected the secret read without echo, got %q", answer)
+	}
+
+	if strings.Contains(output.String(), "sk-secret") {
+		t.Errorf("expected the secret not to be written, got %q", output.String())
+	}
+
+	// A secret given from the environment or a file is not asked for
+	if answer, err := p.askSecret("OpenAI API key", "sk-given"); err != nil || answer != "sk-given" {
+		t.Errorf("expected the given secret, got %q, %v", answer, err)
+	}
+}
+
+//nolint:paralleltest // sets the flag variables of the init command and the environment
+func TestBuildInitConfigFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "openai-api-key.txt")
+	if err := os.WriteFile(keyFile, []byte("sk-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name           string
+		flags          func()
+		env            string
+		input          string
+		nonInteractive bool
+		expected       func(*initConfigFile) bool
+		err            string
+	}{
+		{
+			name: "flags",
+			flags: func() {
+				initEvmAddress = testEvmAddress
+				initDecentralized = []string{"ethereum:core"}
+				initEndpoints = map[string]string{"ethereum": "https://eth.example.com"}
+				initOllamaHost = "http://localhost:11434"
+			},
+			nonInteractive: true,
+			expected: func(c *initConfigFile) bool {
+				return c.Discovery.Operator.EvmAddress == testEvmAddress &&
+					len(c.Component.Decentralized) == 1 && c.Component.Decentralized[0].ID == "ethereum-core" &&
+					c.Endpoints["ethereum"].URL == "https://eth.example.com" &&
+					c.Component.AI.Parameters["ollama_host"] == "http://localhost:11434" && c.Component.RSS == nil
+			},
+		},
+		{
+			name:           "missing evm address",
+			flags:          func() {},
+			nonInteractive: true,
+			err:            "Operator EVM address is required",
+		},
+		{
+			name:  "answers",
+			flags: func() {},
+			// evm address, signature, server endpoint, decentralized workers, federated workers,
+			// ethereum endpoint, rsshub, ai endpoint, openai api key
+			input: "0x1\n" + testEvmAddress + "\n\n\nethereum:core\n\nhttps://eth.example.com\nhttps://rsshub.app\n\nsk-typed\n",
+			expected: func(c *initConfigFile) bool {
+				return c.Discovery.Operator.EvmAddress == testEvmAddress &&
+					c.Endpoints["ethereum"].URL == "https://eth.example.com" &&
+					c.Component.RSS != nil && c.Component.RSS.Endpoint == "https://rsshub.app" &&
+					c.Component.AI.Parameters["openai_api_key"] == "sk-typed"
+			},
+		},
+		{
+			name:           "openai api key of the environment",
+			flags:          func() { initEvmAddress = testEvmAddress },
+			env:            "sk-env",
+			nonInteractive: true,
+			expected: func(c *initConfigFile) bool {
+				return c.Component.AI.Parameters["openai_api_key"] == "sk-env"
+			},
+		},
+		{
+			name: "openai api key file",
+			flags: func() {
+				initEvmAddress = testEvmAddress
+				initOpenAIAPIKeyFile = keyFile
+			},
+			env:            "sk-env",
+			nonInteractive: true,
+			expected: func(c *initConfigFile) bool {
+				return c.Component.AI.Parameters["openai_api_key"] == "sk-file"
+			},
+		},
+		{
+			name:           "without ai component",
+			flags:          func() { initEvmAddress = testEvmAddress },
+			nonInteractive: true,
+			expected: func(c *initConfigFile) bool {
+				return c.Component.AI == nil
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			resetInitFlags(t)
+			testcase.flags()
+			t.Setenv(openAIAPIKeyEnv, testcase.env)
+
+			var output bytes.Buffer
+
+			configFile, err := buildInitConfigFile(&prompter{
+				reader:         bufio.NewReader(strings.NewReader(testcase.input)),
+				writer:         &output,
+				nonInteractive: testcase.nonInteractive,
+			})
+			if testcase.err != "" {
+				if err == nil || err.Error() != testcase.err {
+					t.Fatalf("expected the error %q, got %v", testcase.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("%v, output:\n%s", err, output.String())
+			}
+
+			if !testcase.expected(configFile) {
+				t.Errorf("unexpected config file %+v", configFile)
+			}
+
+			if !strings.HasPrefix(configFile.Discovery.Server.AccessToken, "sk-") {
+				t.Errorf("expected a generated access token, got %q", configFile.Discovery.Server.AccessToken)
+			}
+		})
+	}
+}
+
+// resetInitFlags clears the flag variables of the init command for the test
+func resetInitFlags(t *testing.T) {
+	t.Helper()
+
+	variables := []*string{&initEvmAddress, &initSignature, &initServerEndpoint, &initRSSHub, &initAIEndpoint, &initOpenAIAPIKeyFile, &initOllamaHost}
+	previous := make([]string, len(variables))
+
+	for i, variable := range variables {
+		previous[i], *variable = *variable, ""
+	}
+
+	previousDecentralized, previousFederated, previousEndpoints := initDecentralized, initFederated, initEndpoints
+	initDecentralized, initFederated, initEndpoints = nil, nil, nil
+
+	t.Cleanup(func() {
+		for i, variable := range variables {
+			*variable = previous[i]
+		}
+
+		initDecentralized, initFederated, initEndpoints = previousDecentralized, previousFederated, previousEndpoints
+	})
+}
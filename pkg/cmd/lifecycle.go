@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/docker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	composeFile    = "docker-compose.yaml"
+	composeCommand = ""
+	logsFollow     = false
+	logsTail       = ""
+)
+
+var upCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Generate the docker-compose file and start the node services.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		dockerCompose, err := newDockerCompose(cmd)
+		if err != nil {
+			return err
+		}
+
+		if err := writeComposeFile(file, composeFile); err != nil {
+			return err
+		}
+
+		return dockerCompose.Up(cmd.Context())
+	},
+}
+
+var downCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Stop and remove the node services.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		dockerCompose, err := newDockerCompose(cmd)
+		if err != nil {
+			return err
+		}
+
+		return dockerCompose.Down(cmd.Context())
+	},
+}
+
+var restartCmd = &cobra.Command{
+	Use:   "restart [service...]",
+	Short: "Restart the node services, or only the given ones.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dockerCompose, err := newDockerCompose(cmd)
+		if err != nil {
+			return err
+		}
+
+		return dockerCompose.Restart(cmd.Context(), args...)
+	},
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [service...]",
+	Short: "Show the logs of the node services, or only the given ones.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dockerCompose, err := newDockerCompose(cmd)
+		if err != nil {
+			return err
+		}
+
+		return dockerCompose.Logs(cmd.Context(), logsFollow, logsTail, args...)
+	},
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of the node services.",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		dockerCompose, err := newDockerCompose(cmd)
+		if err != nil {
+			return err
+		}
+
+		statuses, err := dockerCompose.Status(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		if len(statuses) == 0 {
+			fmt.Println("No node services are deployed.")
+
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(w, "SERVICE\tCONTAINER\tSTATE\tHEALTH\tSTATUS")
+
+		for _, status := range statuses {
+			health := status.Health
+			if health == "" {
+				health = "-"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", status.Service, status.Name, status.State, health, status.Status)
+		}
+
+		return w.Flush()
+	},
+}
+
+// newDockerCompose returns a docker compose client for the compose file
+func newDockerCompose(cmd *cobra.Command) (*docker.Compose, error) {
+	var options []docker.Option
+
+	if composeCommand != "" {
+		options = append(options, docker.WithCommand(composeCommand))
+	}
+
+	return docker.NewCompose(cmd.Context(), composeFile, options...)
+}
+
+// writeComposeFile generates the compose service model from the config file and writes it to the compose file
+func writeComposeFile(file string, composeFile string) error {
+	_, composeModel, err := generateCompose(file, false)
+	if err != nil {
+		return err
+	}
+
+	output, err := marshalCompose(composeModel)
+	if err != nil {
+		return fmt.Errorf("write compose file, %w", err)
+	}
+
+	if err := os.WriteFile(composeFile, output, 0600); err != nil {
+		return fmt.Errorf("write compose file, %w", err)
+	}
+
+	return nil
+}
+
+// addComposeFlags adds the flags locating the compose file and the compose CLI to a command
+func addComposeFlags(command *cobra.Command) {
+	command.Flags().StringVar(&composeFile, "compose-file", composeFile, "Path of the docker-compose file")
+	command.Flags().StringVar(&composeCommand, "compose-command", composeCommand, "Compose CLI to run, detected if not set (e.g. \"docker compose\")")
+}
+
+func init() {
+	for _, command := range []*cobra.Command{upCmd, downCmd, restartCmd, logsCmd, statusCmd} {
+		addComposeFlags(command)
+		rootCmd.AddCommand(command)
+	}
+
+	logsCmd.Flags().BoolVar(&logsFollow, "follow", logsFollow, "Follow the log output")
+	logsCmd.Flags().StringVar(&logsTail, "tail", logsTail, "Number of lines to show from the end of the logs")
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/lockfile"
+	"github.com/rss3-network/node-automated-deployer/pkg/secrets"
+)
+
+var refresh = false
+
+// refreshImages resolves the images and the node version again instead of reusing the locked ones, set by upgrade
+var refreshImages = false
+
+// deploymentPlan reuses the decisions of the lockfile and collects where it disagrees with the live config
+type deploymentPlan struct {
+	file   string
+	locked *lockfile.Lockfile
+	drift  []string
+	// nodeVersion is the node version requested before it was resolved
+	nodeVersion string
+	// aiEndpoint is the ai endpoint as written in the config file, its secret references are not resolved
+	aiEndpoint string
+}
+
+// loadPlan reads the lockfile of the config file, ignored with --refresh
+func loadPlan(configFile string) (*deploymentPlan, error) {
+	plan := &deploymentPlan{file: lockfile.Path(configFile)}
+
+	if refresh {
+		return plan, nil
+	}
+
+	locked, err := lockfile.Load(plan.file)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.locked = locked
+
+	return plan, nil
+}
+
+func (p *deploymentPlan) disagree(format string, args ...any) {
+	p.drift = append(p.drift, fmt.Sprintf(format, args...))
+}
+
+// resolveVersions pins the images of the manifest to the locked ones and returns the node version.
+// $NODE_VERSION is resolved against the registry again only when it changed since the lockfile, or on upgrade.
+func (p *deploymentPlan) resolveVersions(manifest *compose.Manifest) (string, error) {
+	p.nodeVersion = manifest.NodeVersion()
+
+	if p.locked == nil || refreshImages {
+		return resolveNodeVersion(manifest)
+	}
+
+	for name, image := range p.locked.Images {
+		current, ok := manifest.Images[name]
+		if !ok || name == "node" {
+			continue
+		}
+
+		if current != image {
+			p.disagree("image %s is locked to %s, the version manifest has %s", name, image.Reference(), current.Reference())
+		}
+
+		manifest.Images[name] = image
+	}
+
+	if node, ok := p.locked.Images["node"]; ok && p.locked.NodeVersion == p.nodeVersion {
+		manifest.Images["node"] = node
+
+		return node.Tag, nil
+	}
+
+	if p.locked.NodeVersion != p.nodeVersion {
+		p.disagree("node version %s is locked, %s is requested and resolved again", p.locked.NodeVersion, p.nodeVersion)
+	}
+
+	return resolveNodeVersion(manifest)
+}
+
+// lockedAI returns the AI component locked for the endpoint, only decisions of --ai=auto are reused
+func (p *deploymentPlan) lockedAI() (compose.AIComponent, bool) {
+	if p.locked == nil || p.locked.AI.Mode != aiModeAuto || p.locked.AI.Endpoint != p.aiEndpoint {
+		return compose.AIComponentOff, false
+	}
+
+	component, err := compose.ParseAIComponent(p.locked.AI.Component)
+
+	return component, err == nil
+}
+
+// checkAI reports an AI component decided differently than the locked one
+func (p *deploymentPlan) checkAI(component compose.AIComponent) {
+	if p.locked == nil {
+		return
+	}
+
+	if p.locked.AI.Endpoint != p.aiEndpoint {
+		p.disagree("ai endpoint %q is locked, the config has %q", p.locked.AI.Endpoint, p.aiEndpoint)
+	}
+
+	if p.locked.AI.Component != component.String() {
+		p.disagree("ai component %s is locked, %s is used now", p.locked.AI.Component, component)
+	}
+}
+
+// lockedHostPorts returns the locked host ports of the services by container port, except the ones --port moves elsewhere
+func (p *deploymentPlan) lockedHostPorts(bindings map[string]compose.PortBinding) map[string]map[int]int {
+	if p.locked == nil {
+		return nil
+	}
+
+	hostPorts := make(map[string]map[int]int, len(p.locked.Ports))
+
+	for name, ports := range p.locked.Ports {
+		if binding := bindings[name]; binding.HostPort != 0 {
+			for _, port := range ports {
+				if port != binding.HostPort {
+					p.disagree("host port %d of %s is locked, --port gives %d", port, shortServiceName(name), binding.HostPort)
+				}
+			}
+
+			continue
+		}
+
+		hostPorts[name] = ports
+	}
+
+	return hostPorts
+}
+
+// checkSecrets reports credentials of the secrets file other than the ones the services were deployed with
+func (p *deploymentPlan) checkSecrets(s *secrets.Secrets) {
+	if p.locked == nil {
+		return
+	}
+
+	for name, fingerprint := range secretFingerprints(s) {
+		if locked, ok := p.locked.Secrets[name]; ok && locked != fingerprint {
+			p.disagree("%s of %s differs from the locked one, the deployed services may not accept it", name, secretsFile)
+		}
+	}
+}
+
+func (p *deploymentPlan) printDrift() {
+	if len(p.drift) == 0 {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Lockfile %s disagrees with the live config, pass --refresh to resolve the plan again:\n", p.file)
+
+	for _, drift := range p.drift {
+		fmt.Fprintf(os.Stderr, "  - %s\n", drift)
+	}
+}
+
+func secretFingerprints(s *secrets.Secrets) map[string]string {
+	return map[string]string{
+		"postgres_password": lockfile.Fingerprint(s.PostgresPassword),
+		"redis_password":    lockfile.Fingerprint(s.RedisPassword),
+	}
+}
+
+// saveLock writes the plan of the generated compose file to the lockfile
+func (d *deployment) saveLock(c *compose.Compose) error {
+	locked := &lockfile.Lockfile{
+		AI: lockfile.AI{
+			Mode:      aiMode,
+			Component: d.aiComponent.String(),
+			Endpoint:  d.plan.aiEndpoint,
+		},
+		NodeVersion: d.plan.nodeVersion,
+		Images:      d.manifest.Images,
+		Ports:       make(map[string]map[int]int),
+		Secrets:     secretFingerprints(d.secrets),
+	}
+
+	for name, service := range c.Services {
+		ports, err := service.PublishedPorts()
+		if err != nil {
+			return err
+		}
+
+		if len(ports) == 0 {
+			continue
+		}
+
+		locked.Ports[name] = make(map[int]int, len(ports))
+
+		for _, port := range ports {
+			locked.Ports[name][port.ContainerPort] = port.HostPort
+		}
+	}
+
+	return locked.Save(d.plan.file)
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&refresh, "refresh", refresh, "Ignore the lockfile next to the config file, probe and resolve the deployment plan again")
+}
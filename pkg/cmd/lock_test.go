@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/lockfile"
+)
+
+//nolint:paralleltest // sets $NODE_VERSION and the refreshImages variable set by upgrade
+func TestResolveVersions(t *testing.T) {
+	t.Setenv("NODE_VERSION", "")
+
+	locked := map[string]compose.Image{
+		"node":  {Repository: "rss3/node", Tag: "v2.0.1", Digest: "sha256:old"},
+		"redis": {Repository: "redis", Tag: "7.4.1-alpine"},
+	}
+
+	testcases := []struct {
+		name     string
+		refresh  bool
+		version  string
+		expected map[string]compose.Image
+		drift    int
+	}{
+		{name: "locked images", version: "v2.0.1", expected: locked, drift: 1},
+		{name: "upgrade", refresh: true, version: "v2.0.1", expected: map[string]compose.Image{
+			"node":  {Repository: "rss3/node", Tag: "v2.0.1", Digest: "sha256:new"},
+			"redis": {Repository: "redis", Tag: "7.4.2-alpine"},
+		}},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			refreshImages = testcase.refresh
+
+			t.Cleanup(func() {
+				refreshImages = false
+			})
+
+			manifest := &compose.Manifest{Images: map[string]compose.Image{
+				"node":  {Repository: "rss3/node", Tag: "v2.0.1", Digest: "sha256:new"},
+				"redis": {Repository: "redis", Tag: "7.4.2-alpine"},
+			}}
+
+			// The locked node image was resolved from the same version, before its tag was pushed again
+			plan := &deploymentPlan{locked: &lockfile.Lockfile{NodeVersion: "v2.0.1", Images: locked}}
+
+			version, err := plan.resolveVersions(manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if version != testcase.version {
+				t.Errorf("expected the node version %s, got %s", testcase.version, version)
+			}
+
+			if !reflect.DeepEqual(manifest.Images, testcase.expected) {
+				t.Errorf("expected the images %v, got %v", testcase.expected, manifest.Images)
+			}
+
+			if len(plan.drift) != testcase.drift {
+				t.Errorf("expected %d differences with the lockfile, got %q", testcase.drift, plan.drift)
+			}
+		})
+	}
+}
+
+func TestLockedHostPorts(t *testing.T) {
+	t.Parallel()
+
+	core, mastodon := compose.ServiceName("core"), compose.WorkerServiceName("mastodon-core")
+
+	plan := &deploymentPlan{locked: &lockfile.Lockfile{Ports: map[string]map[int]int{
+		core:     {80: 8081},
+		mastodon: {8181: 8182, 9090: 9091},
+	}}}
+
+	// --port moves the core service elsewhere, the mastodon worker keeps all its locked ports
+	hostPorts := plan.lockedHostPorts(map[string]compose.PortBinding{core: {HostPort: 8080}})
+
+	if expected := map[string]map[int]int{mastodon: {8181: 8182, 9090: 9091}}; !reflect.DeepEqual(hostPorts, expected) {
+		t.Errorf("expected the host ports %v, got %v", expected, hostPorts)
+	}
+
+	if expected := []string{"host port 8081 of core is locked, --port gives 8080"}; !reflect.DeepEqual(plan.drift, expected) {
+		t.Errorf("expected the differences %q, got %q", expected, plan.drift)
+	}
+}
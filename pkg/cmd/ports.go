@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node/v2/config"
+)
+
+var (
+	portFlags    []string
+	bindAddress  = ""
+	portConflict = portConflictError
+)
+
+const (
+	portConflictError    = "error"
+	portConflictReassign = "reassign"
+	portConflictIgnore   = "ignore"
+)
+
+// maxPortReassignments bounds the search for a free host port when reassigning
+const maxPortReassignments = 100
+
+// parsePortBindings parses the --port flags, service=[host_ip:]host_port where the service is core, agentdata or a worker id
+func parsePortBindings(cfg *config.File) (map[string]compose.PortBinding, error) {
+	if portConflict != portConflictError && portConflict != portConflictReassign && portConflict != portConflictIgnore {
+		return nil, fmt.Errorf("unsupported port conflict handling %s, must be one of %s, %s, %s", portConflict, portConflictError, portConflictReassign, portConflictIgnore)
+	}
+
+	if bindAddress != "" && net.ParseIP(bindAddress) == nil {
+		return nil, fmt.Errorf("invalid bind address %s, must be an IP address", bindAddress)
+	}
+
+	services := map[string]string{
+		"core":      compose.ServiceName("core"),
+		"agentdata": compose.ServiceName("agentdata"),
+	}
+
+	for _, worker := range slices.Concat(cfg.Component.Decentralized, cfg.Component.Federated) {
+		services[worker.ID] = compose.WorkerServiceName(worker.ID)
+	}
+
+	bindings := make(map[string]compose.PortBinding, len(portFlags))
+
+	for _, flag := range portFlags {
+		name, value, ok := strings.Cut(flag, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --port %s, must be service=[host_ip:]host_port", flag)
+		}
+
+		service, ok := services[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown service %s in --port %s, must be core, agentdata or a worker id", name, flag)
+		}
+
+		binding, err := compose.ParsePortBinding(value)
+		if err != nil {
+			return nil, err
+		}
+
+		bindings[service] = binding
+	}
+
+	return bindings, nil
+}
+
+// resolvePortConflicts checks the host ports published by the services are free, ports published by the
+// deployed compose file are assumed to be held by the deployment itself. Unavailable ports are an error,
+// or with --port-conflict reassign are replaced by the next free port.
+func resolvePortConflicts(c *compose.Compose, deployedFile string) error {
+	if portConflict == portConflictIgnore {
+		return nil
+	}
+
+	deployed := deployedHostPorts(deployedFile)
+	published := make(map[compose.PublishedPort]string)
+
+	names := make([]string, 0, len(c.Services))
+	for name := range c.Services {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		service := c.Services[name]
+
+		ports, err := service.PublishedPorts()
+		if err != nil {
+			return err
+		}
+
+		for i, port := range ports {
+			for attempt := 0; ; attempt++ {
+				problem := hostPortProblem(port, name, published, deployed)
+				if problem == "" {
+					break
+				}
+
+				if portConflict == portConflictError {
+					return fmt.Errorf("host port %d of %s %s, choose another one with --port %s=<port> or pass --port-conflict %s",
+						port.HostPort, name, problem, shortServiceName(name), portConflictReassign)
+				}
+
+				if attempt == maxPortReassignments || port.HostPort == 65535 {
+					return fmt.Errorf("no free host port found for %s after %d, %s", name, ports[i].HostPort, problem)
+				}
+
+				port.HostPort++
+			}
+
+			if port.HostPort != ports[i].HostPort {
+				fmt.Fprintf(os.Stderr, "Host port %d of %s is not available, published on %d instead\n", ports[i].HostPort, name, port.HostPort)
+			}
+
+			published[hostKey(port)] = name
+			ports[i] = port
+		}
+
+		service.SetPublishedPorts(ports)
+		c.Services[name] = service
+	}
+
+	return nil
+}
+
+// hostPortProblem returns why the host port can't be published, or an empty string if it can
+func hostPortProblem(port compose.PublishedPort, name string, published map[compose.PublishedPort]string, deployed map[compose.PublishedPort]bool) string {
+	// Several services may bind the same port on different host IPs, unless one of them binds all the interfaces
+	if other := overlappingPort(port, published, name); other != "" {
+		return "is also published by " + other
+	}
+
+	for deployedPort := range deployed {
+		if deployedPort.Overlaps(port) {
+			return ""
+		}
+	}
+
+	listener, err := net.Listen("tcp", port.Address())
+	if err != nil {
+		return fmt.Sprintf("is not available, %v", err)
+	}
+
+	listener.Close()
+
+	return ""
+}
+
+// overlappingPort returns the first other service, by name, publishing a port which overlaps the port
+func overlappingPort(port compose.PublishedPort, published map[compose.PublishedPort]string, name string) string {
+	var others []string
+
+	for publishedPort, other := range published {
+		if other != name && publishedPort.Overlaps(port) {
+			others = append(others, other)
+		}
+	}
+
+	sort.Strings(others)
+
+	if len(others) == 0 {
+		return ""
+	}
+
+	return others[0]
+}
+
+// hostKey keys the published ports by host IP and host port, the container port doesn't matter on the host
+func hostKey(port compose.PublishedPort) compose.PublishedPort {
+	port.ContainerPort = 0
+
+	return port
+}
+
+// deployedHostPorts returns the host IPs and ports published by the deployed compose file, if there is one
+func deployedHostPorts(deployedFile string) map[compose.PublishedPort]bool {
+	ports := make(map[compose.PublishedPort]bool)
+
+	deployed, err := compose.Load(deployedFile)
+	if err != nil {
+		return ports
+	}
+
+	for _, service := range deployed.Services {
+		publishedPorts, _ := service.PublishedPorts()
+		for _, port := range publishedPorts {
+			ports[hostKey(port)] = true
+		}
+	}
+
+	return ports
+}
+
+// shortServiceName returns the name of a service as given to --port, e.g. core for rss3_node_core
+func shortServiceName(name string) string {
+	if short, ok := strings.CutPrefix(name, compose.ServiceName("")); ok {
+		return short
+	}
+
+	return strings.TrimPrefix(name, compose.WorkerServiceName(""))
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringArrayVar(&portFlags, "port", portFlags, "Host port of a service, service=[host_ip:]host_port where the service is core, agentdata or a worker id, repeatable")
+	rootCmd.PersistentFlags().StringVar(&bindAddress, "bind", bindAddress, "Host IP the published ports are bound to, e.g. 127.0.0.1 (default: all interfaces)")
+	rootCmd.PersistentFlags().StringVar(&portConflict, "port-conflict", portConflict, "What to do when a host port is in use, error, reassign or ignore")
+}
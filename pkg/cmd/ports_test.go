@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+)
+
+//nolint:paralleltest // sets the --port-conflict flag variable, which other tests change through the root command
+func TestResolvePortConflicts(t *testing.T) {
+	previous := portConflict
+	portConflict = portConflictError
+
+	t.Cleanup(func() {
+		portConflict = previous
+	})
+
+	const port = "18080"
+
+	testcases := []struct {
+		name     string
+		core     string
+		worker   string
+		conflict string
+	}{
+		{name: "different host ips", core: "127.0.0.1:" + port + ":80", worker: "127.0.0.2:" + port + ":80"},
+		{name: "same host ip", core: "127.0.0.1:" + port + ":80", worker: "127.0.0.1:" + port + ":80", conflict: "also published by node-ethereum-core"},
+		{name: "all interfaces", core: port + ":80", worker: "127.0.0.2:" + port + ":80", conflict: "also published by node-ethereum-core"},
+		{name: "unspecified ip", core: "127.0.0.2:" + port + ":80", worker: "0.0.0.0:" + port + ":80", conflict: "also published by node-ethereum-core"},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &compose.Compose{Services: map[string]compose.Service{
+				compose.ServiceName("core"):                {Ports: []string{testcase.core}},
+				compose.WorkerServiceName("ethereum-core"): {Ports: []string{testcase.worker}},
+			}}
+
+			// The ports are held by the deployment, so they are not probed on addresses the host may not have
+			deployed := filepath.Join(t.TempDir(), "docker-compose.yaml")
+			content := fmt.Sprintf("services:\n  core:\n    image: core\n    ports:\n      - %q\n      - %q\n", testcase.core, testcase.worker)
+
+			if err := os.WriteFile(deployed, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			err := resolvePortConflicts(c, deployed)
+			if testcase.conflict == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), testcase.conflict) {
+				t.Fatalf("expected an error with %q, got %v", testcase.conflict, err)
+			}
+		})
+	}
+}
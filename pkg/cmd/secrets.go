@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/secrets"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	secretsOutput = secretsOutputInline
+	vaultFile     = ""
+)
+
+const (
+	secretsOutputInline = "inline"
+	secretsOutputEnv    = "env"
+	secretsOutputDocker = "docker"
+)
+
+// secretsDir is the directory of the compose secret files, relative to the compose file
+const secretsDir = "secrets"
+
+// renderedConfigDir holds the config file with its secret references resolved, in the config directory mounted into the node services
+const renderedConfigDir = "rendered"
+
+// externalizeSecrets moves the credentials out of the compose file according to --secrets-output,
+// writing them to the .env file and the secret files next to the compose file unless in dry run mode
+func externalizeSecrets(composeModel *compose.Compose, composePath string, dryRun bool) error {
+	dir := filepath.Dir(composePath)
+	files := make(map[string]string)
+
+	switch secretsOutput {
+	case secretsOutputInline:
+		return nil
+	case secretsOutputDocker:
+		for file, content := range compose.MountSecrets(composeModel, secretsDir) {
+			files[filepath.Join(dir, file)] = content
+		}
+	case secretsOutputEnv:
+	default:
+		return fmt.Errorf("unsupported secrets output %s, must be one of %s, %s, %s", secretsOutput, secretsOutputInline, secretsOutputEnv, secretsOutputDocker)
+	}
+
+	variables := compose.ExtractSecrets(composeModel)
+	envFile := filepath.Join(dir, ".env")
+
+	if dryRun {
+		fmt.Fprintf(os.Stderr, "%s would be written with %d variables and %d secret files\n", envFile, len(variables), len(files))
+
+		return nil
+	}
+
+	if len(variables) > 0 {
+		if err := secrets.WriteEnvFile(envFile, variables); err != nil {
+			return err
+		}
+	}
+
+	return secrets.WriteMountedFiles(filepath.Join(dir, secretsDir), files)
+}
+
+// newSecretInterpolator returns the resolver of the secret references in the config file,
+// vault references are read from --vault-file if set, otherwise from the Vault server at VAULT_ADDR
+func newSecretInterpolator() *secrets.Interpolator {
+	var vault secrets.Resolver = secrets.NewVaultResolverFromEnv()
+	if vaultFile != "" {
+		vault = &secrets.FileStoreResolver{File: vaultFile}
+	}
+
+	return secrets.NewInterpolator(secrets.WithResolver("vault", vault))
+}
+
+// newConfigMasker returns a Masker hiding the given values and the values of the sensitive keys of the config files
+func newConfigMasker(values []string, configNodes ...*yaml.Node) *secrets.Masker {
+	for _, node := range configNodes {
+		values = append(values, sensitiveValues(node)...)
+	}
+
+	return secrets.NewMasker(values...)
+}
+
+// sensitiveValues returns the scalar values below the sensitive keys of a YAML tree, e.g. access_token or openai_api_key
+func sensitiveValues(node *yaml.Node) []string {
+	if node == nil {
+		return nil
+	}
+
+	var values []string
+
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if valueNode := node.Content[i+1]; secrets.IsSensitiveKey(node.Content[i].Value) && valueNode.Kind == yaml.ScalarNode {
+				values = append(values, valueNode.Value)
+			}
+		}
+	}
+
+	for _, child := range node.Content {
+		values = append(values, sensitiveValues(child)...)
+	}
+
+	return values
+}
+
+// isTerminal reports whether the file is an interactive terminal rather than a file or a pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&secretsOutput, "secrets-output", secretsOutput,
+		"Where the credentials of the compose file go: inline, env (a .env file next to the compose file) or docker (compose secrets, falling back to the .env file)")
+	rootCmd.PersistentFlags().StringVar(&vaultFile, "vault-file", vaultFile, "Resolve ${vault:path#key} references from a local YAML file mapping every path to its keys, instead of Vault")
+}
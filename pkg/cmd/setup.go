@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/creasty/defaults"
+	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node/v2/config"
+	"github.com/rss3-network/node/v2/schema/worker"
+	"github.com/rss3-network/node/v2/schema/worker/federated"
+	"github.com/rss3-network/node/v2/schema/worker/rss"
+	"github.com/rss3-network/protocol-go/schema/network"
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// deployerConfig is the deployer section of the config file, the settings of the deployment ignored by the node
+type deployerConfig struct {
+	Resources compose.ResourceConfig `yaml:"resources"`
+}
+
+// setupDeployerConfig parses the deployer section of the content of a config file
+func setupDeployerConfig(content []byte) (*deployerConfig, error) {
+	var document struct {
+		Deployer deployerConfig `yaml:"deployer"`
+	}
+
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("parse deployer section: %w", err)
+	}
+
+	if err := document.Deployer.Resources.Validate(); err != nil {
+		return nil, fmt.Errorf("validate deployer section: %w", err)
+	}
+
+	return &document.Deployer, nil
+}
+
+// setupConfig parses the content of a config file the same way config.Setup does,
+// which can only read config files from disk
+func setupConfig(content []byte) (*config.File, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+
+	v.SetEnvPrefix(config.EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	v.AutomaticEnv()
+
+	if err := v.BindEnv("discovery.server.access_token"); err != nil {
+		return nil, err
+	}
+
+	if err := v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	var configFile config.File
+	if err := v.Unmarshal(&configFile, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		network.HookFunc(),
+		worker.HookFunc(),
+		config.EvmAddressHookFunc(),
+	))); err != nil {
+		return nil, fmt.Errorf("unmarshal config file: %w", err)
+	}
+
+	if configFile.Component == nil {
+		return nil, fmt.Errorf("at least 1 component is required")
+	}
+
+	// Convert the federated and RSS worker strings to the correct worker types
+	for _, module := range configFile.Component.Federated {
+		if federatedWorker := federated.GetValueByWorkerStr(module.Worker.Name()); federatedWorker != 0 {
+			module.Worker = federatedWorker
+		}
+	}
+
+	if configFile.Component.RSS != nil {
+		if rssWorker := rss.GetValueByWorkerStr(configFile.Component.RSS.Worker.Name()); rssWorker != 0 {
+			configFile.Component.RSS.Worker = rssWorker
+		}
+	}
+
+	if err := configFile.LoadModulesEndpoint(); err != nil {
+		return nil, fmt.Errorf("build endpoint for modules: %w", err)
+	}
+
+	if err := defaults.Set(&configFile); err != nil {
+		return nil, fmt.Errorf("set default values: %w", err)
+	}
+
+	if envAccessToken := v.GetString("discovery.server.access_token"); envAccessToken != "" {
+		configFile.Discovery.Server.AccessToken = envAccessToken
+	}
+
+	if err := validator.New(validator.WithRequiredStructEnabled()).Struct(&configFile); err != nil {
+		return nil, fmt.Errorf("validate config file: %w", err)
+	}
+
+	return &configFile, nil
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/docker"
+	"github.com/rss3-network/node-automated-deployer/pkg/lockfile"
+	"github.com/rss3-network/node-automated-deployer/pkg/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var (
+	upgradeTimeout = 5 * time.Minute
+	snapshotDir    = "snapshots"
+	snapshotKeep   = 5
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade the node services, rolling back if they don't become healthy.",
+	Long: `Upgrade the node services, rolling back if they don't become healthy.
+The current docker-compose file, config file and the files they refer to are kept as a snapshot, the new docker-compose file is rendered
+and compared with the deployed one, then the services are recreated. If they are not healthy within the timeout,
+the snapshot is restored and the previous services are started again.
+The images are resolved from the version manifest and $NODE_VERSION again, the lockfile doesn't pin them.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if snapshotKeep < 1 {
+			return errors.New("--snapshot-keep must be at least 1, the snapshot of the upgrade is needed to roll back")
+		}
+
+		dockerCompose, err := newDockerCompose(cmd)
+		if err != nil {
+			return err
+		}
+
+		configFile, err := discoverConfigFile(file)
+		if err != nil {
+			return err
+		}
+
+		previous := &compose.Compose{Services: make(map[string]compose.Service)}
+
+		_, err = os.Stat(composeFile)
+		isDeployed := err == nil
+
+		if isDeployed {
+			if previous, err = compose.Load(composeFile); err != nil {
+				return err
+			}
+		}
+
+		composeDir := filepath.Dir(composeFile)
+
+		// The compose file refers to the rendered config, the .env file and the secret files, they are restored together
+		snap, err := snapshot.Create(snapshotDir, composeFile, configFile, lockfile.Path(configFile), secretsFile,
+			filepath.Join(composeDir, ".env"), filepath.Join(composeDir, secretsDir))
+		if err != nil {
+			return err
+		}
+
+		// The rendered config has the secret references of the config file resolved, it is never copied to the snapshot
+		if err := snap.Hold(renderedConfigDir); err != nil {
+			return err
+		}
+
+		// An upgrade deploys the images of the version manifest and $NODE_VERSION, the lockfile only keeps the other decisions
+		refreshImages = true
+		defer func() { refreshImages = false }()
+
+		_, next, err := generateCompose(file, false)
+		if err != nil {
+			return errors.Join(err, snap.Restore())
+		}
+
+		// The snapshot is only kept when the services are recreated
+		diffs := compose.Diff(previous, next)
+		if len(diffs) == 0 {
+			fmt.Println("The deployment is up to date.")
+
+			return snap.Discard()
+		}
+
+		if err := snapshot.Prune(snapshotDir, snapshotKeep); err != nil {
+			return errors.Join(err, snap.Restore())
+		}
+
+		fmt.Printf("Snapshot of the current deployment saved to %s\n", snap.Dir)
+
+		printComposeDiff(os.Stdout, diffs)
+
+		output, err := marshalCompose(next)
+		if err != nil {
+			return errors.Join(err, snap.Restore())
+		}
+
+		if err := os.WriteFile(composeFile, output, 0600); err != nil {
+			return errors.Join(fmt.Errorf("write compose file, %w", err), snap.Restore())
+		}
+
+		services := make([]string, 0, len(next.Services))
+		for name := range next.Services {
+			services = append(services, name)
+		}
+
+		sort.Strings(services)
+
+		err = dockerCompose.Up(cmd.Context())
+		if err == nil {
+			ctx, cancel := context.WithTimeout(cmd.Context(), upgradeTimeout)
+			err = dockerCompose.WaitHealthy(ctx, services, 5*time.Second)
+
+			cancel()
+		}
+
+		if err != nil {
+			fmt.Printf("Upgrade failed, %v, rolling back to %s\n", err, snap.Dir)
+
+			if rollbackErr := rollback(cmd.Context(), dockerCompose, snap, isDeployed); rollbackErr != nil {
+				return errors.Join(err, rollbackErr)
+			}
+
+			return fmt.Errorf("upgrade failed and was rolled back, %w", err)
+		}
+
+		fmt.Println("Upgrade completed successfully.")
+
+		return nil
+	},
+}
+
+// rollback restores the snapshot and starts the previous services again,
+// or removes the new services if nothing was deployed before
+func rollback(ctx context.Context, dockerCompose *docker.Compose, snap *snapshot.Snapshot, isDeployed bool) error {
+	if !isDeployed {
+		if err := dockerCompose.Down(ctx); err != nil {
+			return fmt.Errorf("rollback, %w", err)
+		}
+
+		return snap.Restore()
+	}
+
+	if err := snap.Restore(); err != nil {
+		return fmt.Errorf("rollback, %w", err)
+	}
+
+	if err := dockerCompose.Up(ctx); err != nil {
+		return fmt.Errorf("rollback, %w", err)
+	}
+
+	return nil
+}
+
+// printComposeDiff prints the per service changes between two compose files
+func printComposeDiff(w io.Writer, diffs []compose.ServiceDiff) {
+	for _, diff := range diffs {
+		switch diff.Change {
+		case compose.ChangeAdded:
+			fmt.Fprintf(w, "+ %s\n", diff.Service)
+		case compose.ChangeRemoved:
+			fmt.Fprintf(w, "- %s\n", diff.Service)
+		default:
+			fmt.Fprintf(w, "~ %s\n", diff.Service)
+		}
+
+		for _, field := range diff.Fields {
+			switch {
+			case field.Old == "":
+				fmt.Fprintf(w, "    %s: %s\n", field.Field, field.New)
+			case field.New == "":
+				fmt.Fprintf(w, "    %s: %s -> (removed)\n", field.Field, field.Old)
+			default:
+				fmt.Fprintf(w, "    %s: %s -> %s\n", field.Field, field.Old, field.New)
+			}
+		}
+	}
+}
+
+func init() {
+	addComposeFlags(upgradeCmd)
+	upgradeCmd.Flags().DurationVar(&upgradeTimeout, "timeout", upgradeTimeout, "How long to wait for the services to become healthy before rolling back")
+	upgradeCmd.Flags().StringVar(&snapshotDir, "snapshot-dir", snapshotDir, "Directory keeping the snapshots of previous deployments")
+	upgradeCmd.Flags().IntVar(&snapshotKeep, "snapshot-keep", snapshotKeep, "Number of the latest snapshots to keep, older ones are removed")
+
+	rootCmd.AddCommand(upgradeCmd)
+}
@@ -0,0 +1,391 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/configfile"
+	"github.com/rss3-network/node-automated-deployer/pkg/secrets"
+	"github.com/rss3-network/node/v2/config"
+	"github.com/rss3-network/node/v2/schema/worker/decentralized"
+	"github.com/rss3-network/node/v2/schema/worker/federated"
+	"github.com/rss3-network/protocol-go/schema/network"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// containerNamePattern is the pattern docker accepts for container names
+var containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// aiParameterKeys are the parameters of the AI component understood by the agentdata service
+var aiParameterKeys = map[string][]string{
+	"openai_api_key":  nil,
+	"ollama_host":     nil,
+	"kaito_api_token": nil,
+	"twitter":         {"bearer_token", "api_key", "api_secret", "access_token", "access_token_secret"},
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file for problems before generating the deployment.",
+	Long: `Check the config file for problems before generating the deployment.
+Every problem is reported with its line and column: missing sections, invalid or duplicated worker ids,
+unknown networks and workers, conflicting host ports, AI component parameters and resources.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		discovered, rootNode, _, err := readConfigFile(file)
+		if err != nil {
+			return err
+		}
+
+		problems := validateConfigNode(rootNode)
+
+		// Run the node's own validation as well, it covers the fields not checked above
+		if cfg, err := setupResolvedConfig(cmd, discovered); err != nil {
+			problems = append(problems, configProblem{Message: err.Error()})
+		} else {
+			problems = append(problems, validateHostPorts(rootNode, cfg)...)
+		}
+
+		sort.SliceStable(problems, func(i, j int) bool {
+			return problems[i].Line < problems[j].Line
+		})
+
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem.format(discovered))
+		}
+
+		if len(problems) > 0 {
+			cmd.SilenceUsage = true
+
+			return fmt.Errorf("%d problem(s) found in %s", len(problems), discovered)
+		}
+
+		fmt.Printf("%s is valid.\n", discovered)
+
+		return nil
+	},
+}
+
+// configProblem is a problem found in the config file, at a position if it is known
+type configProblem struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (p configProblem) format(file string) string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", file, p.Message)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s", file, p.Line, p.Column, p.Message)
+}
+
+func newConfigProblem(node *yaml.Node, format string, args ...any) configProblem {
+	return configProblem{
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// validateConfigNode checks the structure of the config file
+func validateConfigNode(rootNode *yaml.Node) []configProblem {
+	if len(rootNode.Content) == 0 || rootNode.Content[0].Kind != yaml.MappingNode {
+		return []configProblem{{Line: 1, Column: 1, Message: "the config file must be a mapping"}}
+	}
+
+	document := rootNode.Content[0]
+
+	var problems []configProblem
+
+	discoveryNode := requireMapping(document, "discovery", &problems)
+	if discoveryNode != nil {
+		requireMapping(discoveryNode, "server", &problems)
+	}
+
+	databaseNode := requireMapping(document, "database", &problems)
+	if databaseNode != nil {
+		if uriNode := configfile.Lookup(databaseNode, "uri"); uriNode == nil {
+			problems = append(problems, newConfigProblem(databaseNode, "database.uri is required"))
+		}
+	}
+
+	componentNode := requireMapping(document, "component", &problems)
+	if componentNode != nil {
+		problems = append(problems, validateWorkers(componentNode)...)
+
+		if aiNode := configfile.Lookup(componentNode, "ai"); aiNode != nil {
+			problems = append(problems, validateAIComponent(aiNode)...)
+		}
+	}
+
+	return problems
+}
+
+// requireMapping returns the mapping under the key, reporting a problem if it is missing or not a mapping
+func requireMapping(parent *yaml.Node, key string, problems *[]configProblem) *yaml.Node {
+	node := configfile.Lookup(parent, key)
+
+	switch {
+	case node == nil:
+		*problems = append(*problems, newConfigProblem(parent, "%s section is required", key))
+	case node.Kind != yaml.MappingNode:
+		*problems = append(*problems, newConfigProblem(node, "%s must be a mapping", key))
+	default:
+		return node
+	}
+
+	return nil
+}
+
+// validateWorkers checks every decentralized and federated worker, and that their ids are unique
+func validateWorkers(componentNode *yaml.Node) []configProblem {
+	var problems []configProblem
+
+	seen := make(map[string]*yaml.Node)
+
+	for _, section := range []string{"decentralized", "federated"} {
+		sequenceNode := configfile.Lookup(componentNode, section)
+		if sequenceNode == nil {
+			continue
+		}
+
+		if sequenceNode.Kind != yaml.SequenceNode {
+			problems = append(problems, newConfigProblem(sequenceNode, "component.%s must be a list", section))
+			continue
+		}
+
+		for index, workerNode := range sequenceNode.Content {
+			path := fmt.Sprintf("component.%s[%d]", section, index)
+
+			if workerNode.Kind != yaml.MappingNode {
+				problems = append(problems, newConfigProblem(workerNode, "%s must be a mapping", path))
+				continue
+			}
+
+			problems = append(problems, validateWorker(path, section, workerNode)...)
+
+			idNode := configfile.Lookup(workerNode, "id")
+			if idNode == nil || idNode.Value == "" {
+				continue
+			}
+
+			if previous, ok := seen[idNode.Value]; ok {
+				problems = append(problems, newConfigProblem(idNode, "duplicated worker id %s, already used at line %d", idNode.Value, previous.Line))
+			}
+
+			seen[idNode.Value] = idNode
+		}
+	}
+
+	return problems
+}
+
+func validateWorker(path string, section string, workerNode *yaml.Node) []configProblem {
+	var problems []configProblem
+
+	idNode := configfile.Lookup(workerNode, "id")
+
+	switch {
+	case idNode == nil || idNode.Value == "":
+		problems = append(problems, newConfigProblem(workerNode, "%s.id is required", path))
+	case !containerNamePattern.MatchString(compose.WorkerServiceName(idNode.Value)):
+		problems = append(problems, newConfigProblem(idNode, "worker id %s is not a valid container name, only [a-zA-Z0-9_.-] are allowed", idNode.Value))
+	}
+
+	if networkNode := configfile.Lookup(workerNode, "network"); networkNode == nil {
+		problems = append(problems, newConfigProblem(workerNode, "%s.network is required", path))
+	} else if _, err := network.NetworkString(networkNode.Value); err != nil {
+		problems = append(problems, newConfigProblem(networkNode, "unknown network %s", networkNode.Value))
+	}
+
+	workerNameNode := configfile.Lookup(workerNode, "worker")
+	if workerNameNode == nil {
+		return append(problems, newConfigProblem(workerNode, "%s.worker is required", path))
+	}
+
+	var err error
+
+	if section == "federated" {
+		_, err = federated.WorkerString(workerNameNode.Value)
+	} else {
+		_, err = decentralized.WorkerString(workerNameNode.Value)
+	}
+
+	if err != nil {
+		problems = append(problems, newConfigProblem(workerNameNode, "unknown %s worker %s", section, workerNameNode.Value))
+	}
+
+	if portNode := configfile.Lookup(workerNode, "parameters", "port"); portNode != nil {
+		if port, err := strconv.Atoi(portNode.Value); err != nil || port <= 0 || port > 65535 {
+			problems = append(problems, newConfigProblem(portNode, "%s.parameters.port must be a port number between 1 and 65535", path))
+		}
+	}
+
+	return problems
+}
+
+// validateAIComponent checks the endpoint and the parameters passed to the agentdata service
+func validateAIComponent(aiNode *yaml.Node) []configProblem {
+	if aiNode.Kind != yaml.MappingNode {
+		return []configProblem{newConfigProblem(aiNode, "component.ai must be a mapping")}
+	}
+
+	var problems []configProblem
+
+	endpointNode := configfile.Lookup(aiNode, "endpoint")
+	if endpointNode != nil && endpointNode.Value != "" {
+		if _, err := url.Parse(normalizeEndpointURL(endpointNode.Value)); err != nil {
+			problems = append(problems, newConfigProblem(endpointNode, "invalid AI endpoint %s, %v", endpointNode.Value, err))
+		}
+	}
+
+	parametersNode := configfile.Lookup(aiNode, "parameters")
+	if parametersNode == nil {
+		if endpointNode == nil || endpointNode.Value == "" {
+			problems = append(problems, newConfigProblem(aiNode, "component.ai needs an endpoint, or parameters to deploy a local agentdata service"))
+		}
+
+		return problems
+	}
+
+	if parametersNode.Kind != yaml.MappingNode {
+		return append(problems, newConfigProblem(parametersNode, "component.ai.parameters must be a mapping"))
+	}
+
+	for i := 0; i+1 < len(parametersNode.Content); i += 2 {
+		keyNode, valueNode := parametersNode.Content[i], parametersNode.Content[i+1]
+
+		nestedKeys, ok := aiParameterKeys[keyNode.Value]
+
+		switch {
+		case !ok:
+			problems = append(problems, newConfigProblem(keyNode, "unknown AI parameter %s", keyNode.Value))
+		case nestedKeys != nil:
+			problems = append(problems, validateNestedParameters("component.ai.parameters."+keyNode.Value, valueNode, nestedKeys)...)
+		case valueNode.Kind != yaml.ScalarNode:
+			problems = append(problems, newConfigProblem(valueNode, "AI parameter %s must be a string", keyNode.Value))
+		case keyNode.Value == "ollama_host" && valueNode.Value != "" && !secrets.HasReferences([]byte(valueNode.Value)):
+			if u, err := url.Parse(valueNode.Value); err != nil || u.Host == "" {
+				problems = append(problems, newConfigProblem(valueNode, "ollama_host must be a URL such as http://localhost:11434"))
+			}
+		}
+	}
+
+	openAIKeyNode := configfile.Lookup(parametersNode, "openai_api_key")
+	ollamaHostNode := configfile.Lookup(parametersNode, "ollama_host")
+
+	if (openAIKeyNode == nil || openAIKeyNode.Value == "") && (ollamaHostNode == nil || ollamaHostNode.Value == "") {
+		problems = append(problems, newConfigProblem(parametersNode, "either openai_api_key or ollama_host is required by the agentdata service"))
+	}
+
+	return problems
+}
+
+func validateNestedParameters(path string, node *yaml.Node, keys []string) []configProblem {
+	if node.Kind != yaml.MappingNode {
+		return []configProblem{newConfigProblem(node, "%s must be a mapping", path)}
+	}
+
+	var problems []configProblem
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+
+		if !slices.Contains(keys, keyNode.Value) {
+			problems = append(problems, newConfigProblem(keyNode, "unknown parameter %s.%s", path, keyNode.Value))
+		}
+	}
+
+	return problems
+}
+
+// setupResolvedConfig parses the config file and its deployer section with the secret references resolved
+func setupResolvedConfig(cmd *cobra.Command, file string) (*config.File, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read config file, %w", err)
+	}
+
+	resolved, err := newSecretInterpolator().Interpolate(cmd.Context(), content)
+	if err != nil {
+		return nil, fmt.Errorf("resolve secret references, %w", err)
+	}
+
+	if _, err := setupDeployerConfig(resolved); err != nil {
+		return nil, err
+	}
+
+	return setupConfig(resolved)
+}
+
+// validateHostPorts reports host ports published by more than one service of the deployment
+func validateHostPorts(rootNode *yaml.Node, cfg *config.File) []configProblem {
+	// Assume the local agentdata service is deployed, the AI endpoint may be unreachable at deploy time
+	composeFile := (&deployment{cfg: cfg, secrets: &secrets.Secrets{}, aiComponent: compose.AIComponentLocal}).newCompose()
+
+	publishers := make(map[string][]string)
+
+	for name, service := range composeFile.Services {
+		for _, port := range service.Ports {
+			parts := strings.Split(port, ":")
+			if len(parts) < 2 {
+				continue
+			}
+
+			hostPort := parts[len(parts)-2]
+			publishers[hostPort] = append(publishers[hostPort], name)
+		}
+	}
+
+	// Locate the workers in the config file, the other services are not configurable
+	workerNodes := make(map[string]*yaml.Node)
+
+	for _, section := range []string{"decentralized", "federated"} {
+		sequenceNode := configfile.Lookup(rootNode.Content[0], "component", section)
+		if sequenceNode == nil {
+			continue
+		}
+
+		for _, workerNode := range sequenceNode.Content {
+			if idNode := configfile.Lookup(workerNode, "id"); idNode != nil {
+				workerNodes[compose.WorkerServiceName(idNode.Value)] = workerNode
+			}
+		}
+	}
+
+	var problems []configProblem
+
+	for hostPort, services := range publishers {
+		if len(services) < 2 {
+			continue
+		}
+
+		sort.Strings(services)
+
+		problem := configProblem{Message: fmt.Sprintf("host port %s is published by %s", hostPort, strings.Join(services, ", "))}
+
+		for _, service := range services {
+			if workerNode, ok := workerNodes[service]; ok {
+				problem.Line, problem.Column = workerNode.Line, workerNode.Column
+				break
+			}
+		}
+
+		problems = append(problems, problem)
+	}
+
+	return problems
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,362 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/health"
+	"github.com/rss3-network/node/v2/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyFormat   = formatText
+	verifyTimeout  = 5 * time.Minute
+	verifyEndpoint = ""
+)
+
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// errVerificationFailed makes the verify command exit with a non-zero status
+var errVerificationFailed = errors.New("verification failed")
+
+type verifyCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+type verifyReport struct {
+	Passed bool          `json:"passed"`
+	Checks []verifyCheck `json:"checks"`
+}
+
+func (r *verifyReport) add(name, status, format string, args ...any) {
+	r.Checks = append(r.Checks, verifyCheck{Name: name, Status: status, Message: fmt.Sprintf(format, args...)})
+
+	if status == checkFail {
+		r.Passed = false
+	}
+}
+
+// workerStatus is a worker as reported by /operators/workers_status of the core API
+type workerStatus struct {
+	WorkerID     string `json:"worker_id"`
+	Status       string `json:"status"`
+	RemoteState  uint64 `json:"remote_state"`
+	IndexedState uint64 `json:"indexed_state"`
+	IndexCount   int64  `json:"index_count"`
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the deployed node services are healthy and serving.",
+	Long: `Check the deployed node services are healthy and serving: every service of the compose file becomes healthy
+within the timeout, the core API answers on its published port, every configured worker reports indexing progress
+and the access token of the config file is accepted. Exits with a non-zero status when a check fails.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if verifyFormat != formatText && verifyFormat != formatJSON {
+			return fmt.Errorf("unsupported format %s, must be one of %s, %s", verifyFormat, formatText, formatJSON)
+		}
+
+		discovered, err := discoverConfigFile(file)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := setupResolvedConfig(cmd, discovered)
+		if err != nil {
+			return err
+		}
+
+		deployed, err := compose.Load(composeFile)
+		if err != nil {
+			return err
+		}
+
+		dockerCompose, err := newDockerCompose(cmd)
+		if err != nil {
+			return err
+		}
+
+		report := &verifyReport{Passed: true}
+
+		// Wait for the containers first, the API checks need the services to be up
+		services := make([]string, 0, len(deployed.Services))
+		for name := range deployed.Services {
+			services = append(services, name)
+		}
+
+		slices.Sort(services)
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), verifyTimeout)
+		err = dockerCompose.WaitHealthy(ctx, services, 5*time.Second)
+
+		cancel()
+
+		if err != nil {
+			report.add("containers", checkFail, "%v", err)
+		} else {
+			report.add("containers", checkPass, "%d services healthy", len(services))
+		}
+
+		endpoint := verifyEndpoint
+		if endpoint == "" {
+			if endpoint, err = coreEndpoint(deployed); err != nil {
+				return err
+			}
+		}
+
+		verifyCoreAPI(cmd.Context(), report, endpoint, cfg)
+
+		if verifyFormat == formatJSON {
+			e := json.NewEncoder(os.Stdout)
+			e.SetIndent("", "  ")
+
+			if err := e.Encode(report); err != nil {
+				return err
+			}
+		} else if err := printVerifyReport(os.Stdout, report); err != nil {
+			return err
+		}
+
+		if !report.Passed {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+
+			return errVerificationFailed
+		}
+
+		return nil
+	},
+}
+
+// coreEndpoint returns the URL of the core API on the host, from the port published by the core service
+func coreEndpoint(c *compose.Compose) (string, error) {
+	ports, err := c.Services[compose.ServiceName("core")].PublishedPorts()
+	if err != nil {
+		return "", err
+	}
+
+	for _, port := range ports {
+		if port.ContainerPort != 80 {
+			continue
+		}
+
+		// A port published on all the interfaces is reached on the loopback one
+		switch port.HostIP {
+		case "", "0.0.0.0":
+			port.HostIP = "127.0.0.1"
+		case "::":
+			port.HostIP = "::1"
+		}
+
+		return "http://" + net.JoinHostPort(port.HostIP, strconv.Itoa(port.HostPort)), nil
+	}
+
+	return "", fmt.Errorf("the core service of %s publishes no port, pass --endpoint", composeFile)
+}
+
+// coreAPIProber probes an endpoint of the core API. A client error status is final, the API is up and refused the request,
+// the other failures are retried since the core service can still be starting when its container is healthy.
+type coreAPIProber struct {
+	*health.HTTPProber
+}
+
+func (p coreAPIProber) Probe(ctx context.Context) (string, error) {
+	detail, err := p.HTTPProber.Probe(ctx)
+
+	var statusError *health.StatusError
+	if errors.As(err, &statusError) && statusError.StatusCode < http.StatusInternalServerError {
+		return detail, health.Permanent(err)
+	}
+
+	return detail, err
+}
+
+// newCoreAPIProber returns a prober of a path of the core API, sending the access token if set
+func newCoreAPIProber(endpoint, path, accessToken string) coreAPIProber {
+	prober := health.NewHTTPProber(endpoint+path, nil)
+
+	if accessToken != "" {
+		prober.Header = http.Header{"Authorization": {"Bearer " + accessToken}}
+	}
+
+	return coreAPIProber{HTTPProber: prober}
+}
+
+// probeFailure describes why the core API failed a probe, the errors of the HTTP client already name the URL
+func probeFailure(result *health.Result) string {
+	var statusError *health.StatusError
+	if errors.As(result.Err(), &statusError) {
+		return fmt.Sprintf("%s returned %s", result.Target, statusError.Status)
+	}
+
+	return result.Error
+}
+
+// verifyCoreAPI checks the core API answers, the workers progress and the access token is accepted.
+// The options change how the requests are retried.
+func verifyCoreAPI(ctx context.Context, report *verifyReport, endpoint string, cfg *config.File, options ...health.Option) {
+	options = append([]health.Option{health.WithAttemptTimeout(10 * time.Second)}, options...)
+
+	if result := health.Check(ctx, newCoreAPIProber(endpoint, "/operators/info", ""), options...); result.Healthy {
+		report.add("core api", checkPass, "%s is serving", endpoint)
+	} else {
+		report.add("core api", checkFail, "%s", probeFailure(result))
+	}
+
+	verifyWorkers(ctx, report, endpoint, cfg, options)
+	verifyAccessToken(ctx, report, endpoint, cfg, options)
+}
+
+func verifyWorkers(ctx context.Context, report *verifyReport, endpoint string, cfg *config.File, options []health.Option) {
+	workers := slices.Concat(cfg.Component.Decentralized, cfg.Component.Federated)
+	if len(workers) == 0 {
+		report.add("workers", checkSkip, "no worker configured")
+
+		return
+	}
+
+	var response struct {
+		Data struct {
+			Decentralized []workerStatus `json:"decentralized"`
+			Federated     []workerStatus `json:"federated"`
+		} `json:"data"`
+	}
+
+	prober := newCoreAPIProber(endpoint, "/operators/workers_status", "")
+	prober.Validate = func(body []byte) (string, error) {
+		if err := json.Unmarshal(body, &response); err != nil {
+			return "", health.Permanent(fmt.Errorf("decode workers status, %w", err))
+		}
+
+		return "", nil
+	}
+
+	if result := health.Check(ctx, prober, options...); !result.Healthy {
+		report.add("workers", checkFail, "%s", probeFailure(result))
+
+		return
+	}
+
+	reported := make(map[string]workerStatus)
+	for _, worker := range slices.Concat(response.Data.Decentralized, response.Data.Federated) {
+		reported[worker.WorkerID] = worker
+	}
+
+	for _, worker := range workers {
+		name := "worker " + worker.ID
+
+		status, ok := reported[worker.ID]
+
+		switch {
+		case !ok:
+			report.add(name, checkFail, "not reported by the core API")
+		case !slices.Contains(compose.HealthyWorkerStatuses, status.Status):
+			report.add(name, checkFail, "status %s", status.Status)
+		case status.IndexedState == 0 && status.IndexCount == 0:
+			report.add(name, checkWarn, "status %s, no progress yet", status.Status)
+		default:
+			report.add(name, checkPass, "status %s, indexed %d of %d, %d activities", status.Status, status.IndexedState, status.RemoteState, status.IndexCount)
+		}
+	}
+}
+
+// verifyAccessToken requests the activities of a configured network, which require the access token.
+// Any answer but 401 and 403 means the token is accepted, the request itself doesn't need to succeed.
+func verifyAccessToken(ctx context.Context, report *verifyReport, endpoint string, cfg *config.File, options []health.Option) {
+	var path string
+
+	switch {
+	case len(cfg.Component.Decentralized) > 0:
+		path = fmt.Sprintf("/decentralized/network/%s", cfg.Component.Decentralized[0].Network)
+	case len(cfg.Component.Federated) > 0:
+		path = fmt.Sprintf("/federated/network/%s", cfg.Component.Federated[0].Network)
+	default:
+		report.add("access token", checkSkip, "no component requiring the access token")
+
+		return
+	}
+
+	var accessToken string
+	if cfg.Discovery != nil && cfg.Discovery.Server != nil {
+		accessToken = cfg.Discovery.Server.AccessToken
+	}
+
+	result := health.Check(ctx, newCoreAPIProber(endpoint, path+"?limit=1", accessToken), options...)
+
+	var statusError *health.StatusError
+
+	switch {
+	case errors.As(result.Err(), &statusError) && (statusError.StatusCode == http.StatusUnauthorized || statusError.StatusCode == http.StatusForbidden):
+		report.add("access token", checkFail, "the access token of the config file is rejected with %d", statusError.StatusCode)
+
+		return
+	case !result.Healthy && statusError == nil:
+		report.add("access token", checkFail, "%s", probeFailure(result))
+
+		return
+	default:
+		report.add("access token", checkPass, "accepted by %s", path)
+	}
+
+	// The same request without the access token must be refused
+	anonymous := health.Check(ctx, newCoreAPIProber(endpoint, path+"?limit=1", ""), append(options, health.WithAttempts(1))...)
+
+	switch {
+	case anonymous.Healthy:
+		report.add("authentication", checkWarn, "%s answers %s without the access token", path, anonymous.Detail)
+	case errors.As(anonymous.Err(), &statusError) && statusError.StatusCode != http.StatusUnauthorized:
+		report.add("authentication", checkWarn, "%s answers %s without the access token", path, statusError.Status)
+	}
+}
+
+func printVerifyReport(w io.Writer, report *verifyReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "STATUS\tCHECK\tMESSAGE")
+
+	for _, check := range report.Checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", strings.ToUpper(check.Status), check.Name, check.Message)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if report.Passed {
+		fmt.Fprintln(w, "\nThe node is up and serving.")
+	} else {
+		fmt.Fprintln(w, "\nThe node is not working as expected, see the failed checks above.")
+	}
+
+	return nil
+}
+
+func init() {
+	addComposeFlags(verifyCmd)
+	verifyCmd.Flags().StringVar(&verifyFormat, "format", verifyFormat, "Output format, text or json")
+	verifyCmd.Flags().DurationVar(&verifyTimeout, "timeout", verifyTimeout, "How long to wait for the services to become healthy")
+	verifyCmd.Flags().StringVar(&verifyEndpoint, "endpoint", verifyEndpoint, "URL of the core API, the port published by the core service on this host if not set")
+
+	rootCmd.AddCommand(verifyCmd)
+}
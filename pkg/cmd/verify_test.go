@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/health"
+	"github.com/rss3-network/node/v2/config"
+	"github.com/rss3-network/node/v2/schema/worker/decentralized"
+	"github.com/rss3-network/protocol-go/schema/network"
+)
+
+func TestCoreEndpoint(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		ports    []string
+		expected string
+		err      string
+	}{
+		{ports: []string{"8080:80"}, expected: "http://127.0.0.1:8080"},
+		{ports: []string{"0.0.0.0:8081:80"}, expected: "http://127.0.0.1:8081"},
+		{ports: []string{"10.0.0.2:8080:80"}, expected: "http://10.0.0.2:8080"},
+		{ports: []string{"[::]:8080:80"}, expected: "http://[::1]:8080"},
+		{ports: []string{"9090:9090", "8082:80"}, expected: "http://127.0.0.1:8082"},
+		{ports: nil, err: "the core service of docker-compose.yaml publishes no port, pass --endpoint"},
+		{ports: []string{"core:80"}, err: "invalid published port core:80"},
+	}
+
+	for _, testcase := range testcases {
+		c := &compose.Compose{Services: map[string]compose.Service{compose.ServiceName("core"): {Ports: testcase.ports}}}
+
+		endpoint, err := coreEndpoint(c)
+		if testcase.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), testcase.err) {
+				t.Errorf("expected the error %q for %q, got %v", testcase.err, testcase.ports, err)
+			}
+
+			continue
+		}
+
+		if err != nil || endpoint != testcase.expected {
+			t.Errorf("expected the endpoint %s for %q, got %s, %v", testcase.expected, testcase.ports, endpoint, err)
+		}
+	}
+}
+
+// fakeCoreAPI answers the requests of verify like the core API of a node running the ethereum-core worker
+type fakeCoreAPI struct {
+	info int
+	// unavailable is the number of requests to /operators/info answered with 503, while the core service starts
+	unavailable  int32
+	infoRequests *atomic.Int32
+	// workers is the status code of /operators/workers_status, worker the status it reports for ethereum-core
+	workers      int
+	worker       string
+	indexedState int
+	// authenticated and anonymous are the status codes of the activities with and without the access token
+	authenticated int
+	anonymous     int
+}
+
+func (f fakeCoreAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/operators/info":
+		if f.infoRequests.Add(1) <= f.unavailable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.WriteHeader(f.info)
+	case "/operators/workers_status":
+		w.WriteHeader(f.workers)
+
+		var decentralized string
+		if f.worker != "" {
+			decentralized = fmt.Sprintf(`{"worker_id": "ethereum-core", "status": %q, "remote_state": 21000000, "indexed_state": %d}`, f.worker, f.indexedState)
+		}
+
+		fmt.Fprintf(w, `{"data": {"decentralized": [%s], "federated": []}}`, decentralized)
+	case "/decentralized/network/ethereum":
+		if r.Header.Get("Authorization") == "Bearer sk-test" {
+			w.WriteHeader(f.authenticated)
+		} else {
+			w.WriteHeader(f.anonymous)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestVerifyCoreAPI(t *testing.T) {
+	t.Parallel()
+
+	healthy := fakeCoreAPI{
+		info:          http.StatusOK,
+		workers:       http.StatusOK,
+		worker:        "Indexing",
+		indexedState:  20000000,
+		authenticated: http.StatusOK,
+		anonymous:     http.StatusUnauthorized,
+	}
+
+	workers := []*config.Module{{ID: "ethereum-core", Network: network.Ethereum, Worker: decentralized.Core}}
+
+	testcases := []struct {
+		name     string
+		core     func(f fakeCoreAPI) fakeCoreAPI
+		workers  []*config.Module
+		expected []verifyCheck
+		passed   bool
+	}{
+		{
+			name:    "healthy",
+			workers: workers,
+			expected: []verifyCheck{
+				{Name: "core api", Status: checkPass},
+				{Name: "worker ethereum-core", Status: checkPass},
+				{Name: "access token", Status: checkPass},
+			},
+			passed: true,
+		},
+		{
+			name:    "core api starting",
+			core:    func(f fakeCoreAPI) fakeCoreAPI { f.unavailable = 2; return f },
+			workers: workers,
+			expected: []verifyCheck{
+				{Name: "core api", Status: checkPass},
+				{Name: "worker ethereum-core", Status: checkPass},
+				{Name: "access token", Status: checkPass},
+			},
+			passed: true,
+		},
+		{
+			name:    "core api unavailable",
+			core:    func(f fakeCoreAPI) fakeCoreAPI { f.unavailable = 3; return f },
+			workers: workers,
+			expected: []verifyCheck{
+				{Name: "core api", Status: checkFail},
+				{Name: "worker ethereum-core", Status: checkPass},
+				{Name: "access token", Status: checkPass},
+			},
+		},
+		{
+			name:    "worker without progress",
+			core:    func(f fakeCoreAPI) fakeCoreAPI { f.worker, f.indexedState = "Ready", 0; return f },
+			workers: workers,
+			expected: []verifyCheck{
+				{Name: "core api", Status: checkPass},
+				{Name: "worker ethereum-core", Status: checkWarn},
+				{Name: "access token", Status: checkPass},
+			},
+			passed: true,
+		},
+		{
+			name:    "access token not required",
+			core:    func(f fakeCoreAPI) fakeCoreAPI { f.anonymous = http.StatusOK; return f },
+			workers: workers,
+			expected: []verifyCheck{
+				{Name: "core api", Status: checkPass},
+				{Name: "worker ethereum-core", Status: checkPass},
+				{Name: "access token", Status: checkPass},
+				{Name: "authentication", Status: checkWarn},
+			},
+			passed: true,
+		},
+		{
+			name: "no workers",
+			expected: []verifyCheck{
+				{Name: "core api", Status: checkPass},
+				{Name: "workers", Status: checkSkip},
+				{Name: "access token", Status: checkSkip},
+			},
+			passed: true,
+		},
+		{
+			name: "unhealthy",
+			core: func(f fakeCoreAPI) fakeCoreAPI {
+				f.info, f.worker, f.authenticated = http.StatusNotFound, "Unhealthy", http.StatusForbidden
+				return f
+			},
+			workers: workers,
+			expected: []verifyCheck{
+				{Name: "core api", Status: checkFail},
+				{Name: "worker ethereum-core", Status: checkFail},
+				{Name: "access token", Status: checkFail},
+			},
+		},
+		{
+			name:    "worker not reported",
+			core:    func(f fakeCoreAPI) fakeCoreAPI { f.worker = ""; return f },
+			workers: workers,
+			expected: []verifyCheck{
+				{Name: "core api", Status: checkPass},
+				{Name: "worker ethereum-core", Status: checkFail},
+				{Name: "access token", Status: checkPass},
+			},
+		},
+		{
+			name:    "workers status failing",
+			core:    func(f fakeCoreAPI) fakeCoreAPI { f.workers = http.StatusNotFound; return f },
+			workers: workers,
+			expected: []verifyCheck{
+				{Name: "core api", Status: checkPass},
+				{Name: "workers", Status: checkFail},
+				{Name: "access token", Status: checkPass},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			core := healthy
+			core.infoRequests = new(atomic.Int32)
+
+			if testcase.core != nil {
+				core = testcase.core(core)
+			}
+
+			server := httptest.NewServer(core)
+			t.Cleanup(server.Close)
+
+			cfg := &config.File{
+				Discovery: &config.Discovery{Server: &config.Server{AccessToken: "sk-test"}},
+				Component: &config.Component{Decentralized: testcase.workers},
+			}
+
+			report := &verifyReport{Passed: true}
+			verifyCoreAPI(context.Background(), report, server.URL, cfg, health.WithBackoff(health.Backoff{Initial: time.Millisecond}))
+
+			// The messages are for people, only the checks and their status are compared
+			checks := make([]verifyCheck, 0, len(report.Checks))
+			for _, check := range report.Checks {
+				checks = append(checks, verifyCheck{Name: check.Name, Status: check.Status})
+			}
+
+			if !reflect.DeepEqual(checks, testcase.expected) {
+				t.Errorf("expected the checks %v, got %+v", testcase.expected, report.Checks)
+			}
+
+			if report.Passed != testcase.passed {
+				t.Errorf("expected the report to pass: %v, got %+v", testcase.passed, report.Checks)
+			}
+		})
+	}
+}
+
+func TestPrintVerifyReport(t *testing.T) {
+	t.Parallel()
+
+	report := &verifyReport{Passed: true}
+	report.add("core api", checkPass, "%s is serving", "http://127.0.0.1:8080")
+	report.add("worker ethereum-core", checkWarn, "status %s, no progress yet", "Ready")
+
+	var buffer bytes.Buffer
+	if err := printVerifyReport(&buffer, report); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `STATUS  CHECK                 MESSAGE
+PASS    core api              http://127.0.0.1:8080 is serving
+WARN    worker ethereum-core  status Ready, no progress yet
+
+The node is up and serving.
+`
+	if buffer.String() != expected {
+		t.Errorf("expected the report\n%s\ngot\n%s", expected, buffer.String())
+	}
+
+	// A failed check fails the whole report
+	report.add("access token", checkFail, "the access token of the config file is rejected with %d", http.StatusForbidden)
+	buffer.Reset()
+
+	if err := printVerifyReport(&buffer, report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Passed || !strings.HasSuffix(buffer.String(), "\nThe node is not working as expected, see the failed checks above.\n") {
+		t.Errorf("expected a failed report, got\n%s", buffer.String())
+	}
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/configfile"
+	"github.com/rss3-network/node-automated-deployer/pkg/registry"
+	"github.com/spf13/cobra"
+)
+
+// deployerVersion is the release of the deployer, set at build time with
+// -ldflags "-X github.com/rss3-network/node-automated-deployer/pkg/cmd.deployerVersion=v0.6.2"
+var deployerVersion = "dev"
+
+var (
+	versionsFormat = formatText
+	// nodeRegistry is the registry NODE_VERSION is resolved against, a mirror of ghcr.io can be set by $NODE_REGISTRY
+	nodeRegistry = cmp.Or(os.Getenv("NODE_REGISTRY"), "https://ghcr.io")
+)
+
+var versionsCmd = &cobra.Command{
+	Use:   "versions",
+	Short: "Print the images deployed by this release of the deployer.",
+	Long: `Print the images deployed by this release of the deployer, from the embedded version manifest
+or the one given by --versions-file. The node version can still be overridden by $NODE_VERSION,
+a tag, latest-stable or a range such as ~2.0, resolved against the registry and pinned by digest.`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		if versionsFormat != formatText && versionsFormat != formatJSON {
+			return fmt.Errorf("unsupported format %s, must be one of %s, %s", versionsFormat, formatText, formatJSON)
+		}
+
+		manifest, err := loadManifest()
+		if err != nil {
+			return err
+		}
+
+		if _, err := resolveNodeVersion(manifest); err != nil {
+			return err
+		}
+
+		if versionsFormat == formatJSON {
+			e := json.NewEncoder(os.Stdout)
+			e.SetIndent("", "  ")
+
+			return e.Encode(manifest)
+		}
+
+		return printManifest(os.Stdout, manifest)
+	},
+}
+
+var versionsPinCmd = &cobra.Command{
+	Use:   "pin",
+	Short: "Pin the images of a version manifest to exact tags and their digests.",
+	Long: `Pin the images of the version manifest given by --versions-file: a mutable tag such as latest
+is replaced by the newest release of the repository, and the digest of every tag is resolved against its registry.
+The file is rewritten in place, keeping its comments. Run it on pkg/compose/manifest.yaml before a release.`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		if versionsFile == "" {
+			return errors.New("no version manifest to pin, set --versions-file")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+
+		return pinManifest(ctx, versionsFile, registry.Locate)
+	},
+}
+
+// loadManifest returns the version manifest of the running release.
+// Images which are not pinned by an exact tag and digest are refused, unless --allow-unpinned is set.
+func loadManifest() (*compose.Manifest, error) {
+	manifestFile, err := compose.LoadManifestFile(versionsFile, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := manifestFile.CheckPinned(); err != nil {
+		if !allowUnpinned {
+			return nil, fmt.Errorf("version manifest is not pinned, pin it with `versions pin` or pass --allow-unpinned\n%w", err)
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: deploying images which are not pinned\n%v\n", err)
+	}
+
+	return manifestFile.Select(deployerVersion), nil
+}
+
+// pinManifest resolves the tag and digest of every image of the manifest file and writes them back to it.
+// The images which can't be resolved are reported after the others are written.
+func pinManifest(ctx context.Context, file string, locate func(repository string) (string, string)) error {
+	manifestFile, err := compose.LoadManifestFile(file, true)
+	if err != nil {
+		return err
+	}
+
+	configFile, err := configfile.Load(file)
+	if err != nil {
+		return err
+	}
+
+	clients := make(map[string]*registry.Client)
+
+	var errs []error
+
+	for index, manifest := range manifestFile.Releases {
+		names := make([]string, 0, len(manifest.Images))
+		for name := range manifest.Images {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			image := manifest.Images[name]
+
+			baseURL, repository := locate(image.Repository)
+			if clients[baseURL] == nil {
+				clients[baseURL] = registry.NewClient(baseURL)
+			}
+
+			tag, digest, err := pinImage(ctx, clients[baseURL], repository, image.Tag)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("pin %s of release %s, %w", name, manifest.Release, err))
+
+				continue
+			}
+
+			path := []string{"releases", strconv.Itoa(index), "images", name}
+
+			if err := configFile.Set(append(path, "tag"), tag); err != nil {
+				return fmt.Errorf("set tag of %s, %w", name, err)
+			}
+
+			if err := configFile.Set(append(path, "digest"), digest); err != nil {
+				return fmt.Errorf("set digest of %s, %w", name, err)
+			}
+
+			fmt.Printf("%s %s:%s@%s\n", name, image.Repository, tag, digest)
+		}
+	}
+
+	if err := configFile.Save(); err != nil {
+		return err
+	}
+
+	return errors.Join(errs...)
+}
+
+// pinImage returns the digest of an exact tag, a mutable tag is replaced by the newest release first
+func pinImage(ctx context.Context, client *registry.Client, repository, tag string) (string, string, error) {
+	if compose.IsMutableTag(tag) {
+		return client.Resolve(ctx, repository, registry.LatestStable)
+	}
+
+	digest, err := client.Digest(ctx, repository, tag)
+
+	return tag, digest, err
+}
+
+// resolveNodeVersion resolves $NODE_VERSION against the registry and pins the node image of the manifest to the
+// selected tag and its digest. The node version of a pinned manifest is kept as is, so deploying it needs no registry access.
+// A version whose digest can't be resolved is refused, unless --allow-unpinned deploys it by tag.
+func resolveNodeVersion(manifest *compose.Manifest) (string, error) {
+	version := manifest.NodeVersion()
+	node := manifest.Image("node")
+
+	if version == node.Tag && node.Digest != "" {
+		return version, nil
+	}
+
+	constraint, err := registry.ParseConstraint(version)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, repository, _ := strings.Cut(node.Repository, "/")
+
+	tag, digest, err := registry.NewClient(nodeRegistry).Resolve(ctx, repository, version)
+
+	switch {
+	case err == nil:
+	case errors.Is(err, registry.ErrRepositoryNotFound):
+		return "", fmt.Errorf("resolve NODE_VERSION %s, %w", version, err)
+	case errors.Is(err, registry.ErrTagNotFound) || constraint.IsRange():
+		return "", fmt.Errorf("invalid NODE_VERSION %s, %w", version, err)
+	case !allowUnpinned:
+		return "", fmt.Errorf("resolve the digest of node version %s, pass --allow-unpinned to deploy it by tag, %w", version, err)
+	default:
+		// An exact tag can still be deployed when the registry is unreachable, it is checked again at pull time
+		fmt.Fprintf(os.Stderr, "Warning: node version %s not pinned by digest, %v\n", version, err)
+
+		tag = version
+	}
+
+	manifest.Images["node"] = compose.Image{Repository: node.Repository, Tag: tag, Digest: digest}
+
+	return tag, nil
+}
+
+func printManifest(w io.Writer, manifest *compose.Manifest) error {
+	fmt.Fprintf(w, "deployer %s, images of release %s\n\n", deployerVersion, manifest.Release)
+
+	names := make([]string, 0, len(manifest.Images))
+	for name := range manifest.Images {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "IMAGE\tREPOSITORY\tTAG\tDIGEST")
+
+	for _, name := range names {
+		image := manifest.Image(name)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, image.Repository, image.Tag, cmp.Or(image.Digest, "-"))
+	}
+
+	return tw.Flush()
+}
+
+func init() {
+	versionsCmd.Flags().StringVar(&versionsFormat, "format", versionsFormat, "Output format, text or json")
+
+	rootCmd.Version = deployerVersion
+	versionsCmd.AddCommand(versionsPinCmd)
+	rootCmd.AddCommand(versionsCmd)
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+)
+
+func TestPinManifest(t *testing.T) {
+	t.Parallel()
+
+	digest := "sha256:" + strings.Repeat("1", 64)
+
+	// A registry serving the tags of agentdata and the manifests of every tag but the one of postgres
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/rss3-network/agentdata/tags/list":
+			_, _ = w.Write([]byte(`{"tags": ["latest", "v1.0.0", "v1.1.0", "v1.2.0-rc.1"]}`))
+		case "/v2/rss3-network/node/manifests/v2.0.0", "/v2/rss3-network/agentdata/manifests/v1.1.0", "/v2/library/redis/manifests/7.4.1-alpine":
+			w.Header().Set("Docker-Content-Digest", digest)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	file := filepath.Join(t.TempDir(), "versions.yaml")
+	content := `releases:
+  - release: v0.6.2
+    images:
+      node:
+        repository: ghcr.io/rss3-network/node
+        tag: v2.0.0
+      agentdata:
+        # no versioned tags yet
+        repository: ghcr.io/rss3-network/agentdata
+        tag: latest
+      redis:
+        repository: redis
+        tag: 7.4.1-alpine
+      postgres:
+        repository: postgres
+        tag: "15.10"
+`
+
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	locate := func(repository string) (string, string) {
+		if path, found := strings.CutPrefix(repository, "ghcr.io/"); found {
+			return server.URL, path
+		}
+
+		return server.URL, "library/" + repository
+	}
+
+	err := pinManifest(context.Background(), file, locate)
+	if err == nil || !strings.Contains(err.Error(), "pin postgres of release v0.6.2") {
+		t.Fatalf("expected postgres not to be pinned, got %v", err)
+	}
+
+	pinned, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The pinned images are written in place, the comments kept
+	expected := strings.NewReplacer(
+		"tag: v2.0.0\n", "tag: v2.0.0\n        digest: "+digest+"\n",
+		"tag: latest\n", "tag: v1.1.0\n        digest: "+digest+"\n",
+		"tag: 7.4.1-alpine\n", "tag: 7.4.1-alpine\n        digest: "+digest+"\n",
+	).Replace(content)
+
+	if string(pinned) != expected {
+		t.Errorf("expected the pinned manifest\n%s\ngot\n%s", expected, pinned)
+	}
+
+	manifestFile, err := compose.LoadManifestFile(file, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manifestFile.CheckPinned(); err == nil || err.Error() != "release v0.6.2 of the version manifest has no sha256 digest for postgres" {
+		t.Errorf("expected only postgres to be unpinned, got %v", err)
+	}
+}
+
+//nolint:paralleltest // sets $NODE_VERSION and the registry and --allow-unpinned variables
+func TestResolveNodeVersion(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("2", 64)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/rss3-network/node/tags/list":
+			_, _ = w.Write([]byte(`{"tags": ["v2.0.0", "v2.0.1"]}`))
+		case "/v2/rss3-network/node/manifests/v2.0.0", "/v2/rss3-network/node/manifests/v2.0.1":
+			w.Header().Set("Docker-Content-Digest", digest)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	testcases := []struct {
+		name          string
+		version       string
+		registry      string
+		allowUnpinned bool
+		node          compose.Image
+		expected      compose.Image
+		err           string
+	}{
+		{
+			name:     "pinned default version",
+			registry: "http://127.0.0.1:1",
+			node:     compose.Image{Repository: compose.NodeImage, Tag: "v2.0.0", Digest: "sha256:pinned"},
+			expected: compose.Image{Repository: compose.NodeImage, Tag: "v2.0.0", Digest: "sha256:pinned"},
+		},
+		{
+			name:     "unpinned default version",
+			registry: server.URL,
+			node:     compose.Image{Repository: compose.NodeImage, Tag: "v2.0.0"},
+			expected: compose.Image{Repository: compose.NodeImage, Tag: "v2.0.0", Digest: digest},
+		},
+		{
+			name:     "range",
+			version:  "~2.0",
+			registry: server.URL,
+			node:     compose.Image{Repository: compose.NodeImage, Tag: "v2.0.0", Digest: "sha256:pinned"},
+			expected: compose.Image{Repository: compose.NodeImage, Tag: "v2.0.1", Digest: digest},
+		},
+		{
+			name:     "unreachable registry",
+			version:  "v2.0.1",
+			registry: "http://127.0.0.1:1",
+			node:     compose.Image{Repository: compose.NodeImage, Tag: "v2.0.0", Digest: "sha256:pinned"},
+			err:      "resolve the digest of node version v2.0.1, pass --allow-unpinned to deploy it by tag",
+		},
+		{
+			name:          "unreachable registry allowing unpinned",
+			version:       "v2.0.1",
+			registry:      "http://127.0.0.1:1",
+			allowUnpinned: true,
+			node:          compose.Image{Repository: compose.NodeImage, Tag: "v2.0.0", Digest: "sha256:pinned"},
+			expected:      compose.Image{Repository: compose.NodeImage, Tag: "v2.0.1"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Setenv("NODE_VERSION", testcase.version)
+
+			nodeRegistry, allowUnpinned = testcase.registry, testcase.allowUnpinned
+
+			t.Cleanup(func() {
+				nodeRegistry, allowUnpinned = "https://ghcr.io", false
+			})
+
+			manifest := &compose.Manifest{Images: map[string]compose.Image{"node": testcase.node}}
+
+			_, err := resolveNodeVersion(manifest)
+			if testcase.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), testcase.err) {
+					t.Fatalf("expected the error %q, got %v", testcase.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if node := manifest.Image("node"); node != testcase.expected {
+				t.Errorf("expected the node image %v, got %v", testcase.expected, node)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package compose
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -14,14 +15,16 @@ import (
 
 type Compose struct {
 	Services map[string]Service
-	Volumes  map[string]*string
+	Volumes  map[string]*string    `yaml:"volumes,omitempty"`
+	Secrets  map[string]SecretFile `yaml:"secrets,omitempty"`
 }
 
 type Healthcheck struct {
-	Test     []string      `yaml:"test,omitempty"`
-	Interval time.Duration `yaml:"interval,omitempty"`
-	Timeout  time.Duration `yaml:"timeout,omitempty"`
-	Retries  int           `yaml:"retries,omitempty"`
+	Test        []string      `yaml:"test,omitempty"`
+	Interval    time.Duration `yaml:"interval,omitempty"`
+	Timeout     time.Duration `yaml:"timeout,omitempty"`
+	Retries     int           `yaml:"retries,omitempty"`
+	StartPeriod time.Duration `yaml:"start_period,omitempty"`
 }
 
 type DependsOn struct {
@@ -39,6 +42,12 @@ type Service struct {
 	Volumes       []string             `yaml:"volumes,omitempty"`
 	Healthcheck   Healthcheck          `yaml:"healthcheck,omitempty"`
 	DependsOn     map[string]DependsOn `yaml:"depends_on,omitempty"`
+	Secrets       []string             `yaml:"secrets,omitempty"`
+	Deploy        *Deploy              `yaml:"deploy,omitempty"`
+	MemLimit      string               `yaml:"mem_limit,omitempty"`
+	CPUs          float64              `yaml:"cpus,omitempty"`
+	ShmSize       string               `yaml:"shm_size,omitempty"`
+	Ulimits       map[string]Ulimit    `yaml:"ulimits,omitempty"`
 }
 
 type AIComponentParameters struct {
@@ -61,15 +70,28 @@ type Option func(*Compose)
 // use a prefix to avoid conflict with other containers
 const dockerComposeContainerNamePrefix = "rss3_node"
 
+// NodeImage is the image of all the rss3 node services
+const NodeImage = "ghcr.io/rss3-network/node"
+
+// ServiceName returns the name of a service managed by the deployer, e.g. rss3_node_core
+func ServiceName(name string) string {
+	return fmt.Sprintf("%s_%s", dockerComposeContainerNamePrefix, name)
+}
+
+// WorkerServiceName returns the name of the service running the worker with the given id
+func WorkerServiceName(id string) string {
+	return fmt.Sprintf("node-%s", id)
+}
+
 func NewCompose(options ...Option) *Compose {
-	alloydbVolume := "alloydb"
+	databaseBackend := DatabaseBackends[DefaultDatabaseBackend]
 
 	compose := &Compose{
 		Services: map[string]Service{
 			fmt.Sprintf("%s_redis", dockerComposeContainerNamePrefix): {
 				ContainerName: fmt.Sprintf("%s_redis", dockerComposeContainerNamePrefix),
 				Expose:        []string{"6379"},
-				Image:         "redis:7-alpine",
+				Image:         defaultManifest.Image("redis").Reference(),
 				Healthcheck: Healthcheck{
 					Test:     []string{"CMD", "redis-cli", "ping"},
 					Interval: 5 * time.Second,
@@ -77,42 +99,29 @@ func NewCompose(options ...Option) *Compose {
 					Retries:  3,
 				},
 			},
-			fmt.Sprintf("%s_alloydb", dockerComposeContainerNamePrefix): {
-				ContainerName: fmt.Sprintf("%s_alloydb", dockerComposeContainerNamePrefix),
-				Expose:        []string{"5432"},
-				Image:         "google/alloydbomni:latest",
-				Volumes:       []string{fmt.Sprintf("%s:/var/lib/postgresql/data", alloydbVolume)},
-				Environment: map[string]string{
-					"DATA_DIR":          "/var/lib/postgresql/data",
-					"HOST_PORT":         "5432",
-					"POSTGRES_PASSWORD": "password",
-				},
-				Healthcheck: Healthcheck{
-					Test:     []string{"CMD-SHELL", "pg_isready -U postgres"},
-					Interval: 5 * time.Second,
-					Timeout:  5 * time.Second,
-					Retries:  5,
-				},
-			},
+			fmt.Sprintf("%s_alloydb", dockerComposeContainerNamePrefix): newDatabaseService(databaseBackend),
 			fmt.Sprintf("%s_core", dockerComposeContainerNamePrefix): {
 				Command:       "--module=core",
 				ContainerName: fmt.Sprintf("%s_core", dockerComposeContainerNamePrefix),
 				Ports:         []string{"8080:80"},
-				Image:         "ghcr.io/rss3-network/node",
+				Image:         defaultManifest.Image("node").Reference(),
+				Healthcheck:   coreHealthcheck(),
 			},
 			fmt.Sprintf("%s_monitor", dockerComposeContainerNamePrefix): {
 				Command:       "--module=monitor",
 				ContainerName: fmt.Sprintf("%s_monitor", dockerComposeContainerNamePrefix),
-				Image:         "ghcr.io/rss3-network/node",
+				Image:         defaultManifest.Image("node").Reference(),
+				Healthcheck:   moduleHealthcheck("monitor"),
 			},
 			fmt.Sprintf("%s_broadcaster", dockerComposeContainerNamePrefix): {
 				Command:       "--module=broadcaster",
 				ContainerName: fmt.Sprintf("%s_broadcaster", dockerComposeContainerNamePrefix),
-				Image:         "ghcr.io/rss3-network/node",
+				Image:         defaultManifest.Image("node").Reference(),
+				Healthcheck:   moduleHealthcheck("broadcaster"),
 			},
 		},
 		Volumes: map[string]*string{
-			alloydbVolume: nil,
+			databaseBackend.Volume(): nil,
 		},
 	}
 
@@ -123,12 +132,18 @@ func NewCompose(options ...Option) *Compose {
 	return compose
 }
 
+// SetNodeVersion sets the tag of the node images, keeping the digest of the default manifest if the tag matches
 func SetNodeVersion(version string) Option {
+	image := defaultManifest.Image("node")
+	if version != image.Tag {
+		image = Image{Repository: NodeImage, Tag: version}
+	}
+
 	return func(c *Compose) {
 		services := c.Services
 		for k, v := range services {
-			if strings.Contains(v.Image, "ghcr.io/rss3-network/node") {
-				v.Image = fmt.Sprintf("ghcr.io/rss3-network/node:%s", version)
+			if strings.Contains(v.Image, NodeImage) {
+				v.Image = image.Reference()
 				c.Services[k] = v
 			}
 		}
@@ -137,12 +152,13 @@ func SetNodeVersion(version string) Option {
 	}
 }
 
-func SetNodeVolume() Option {
+// SetNodeVolume mounts the directory of the config file, relative to the working directory, into the node services
+func SetNodeVolume(configDir string) Option {
 	return func(c *Compose) {
 		services := c.Services
 		for k, v := range services {
-			if strings.Contains(v.Image, "ghcr.io/rss3-network/node") {
-				v.Volumes = append(v.Volumes, "${PWD}/config:/etc/rss3/node")
+			if strings.Contains(v.Image, NodeImage) {
+				v.Volumes = append(v.Volumes, fmt.Sprintf("${PWD}/%s:/etc/rss3/node", configDir))
 				c.Services[k] = v
 			}
 		}
@@ -173,11 +189,12 @@ func WithWorkers(workers []*config.Module) Option {
 		services := c.Services
 
 		for _, worker := range workers {
-			name := fmt.Sprintf("node-%s", worker.ID)
+			name := WorkerServiceName(worker.ID)
 			services[name] = Service{
 				Command:       fmt.Sprintf("--module=worker --worker.id=%s", worker.ID),
 				ContainerName: name,
-				Image:         "ghcr.io/rss3-network/node",
+				Image:         defaultManifest.Image("node").Reference(),
+				Healthcheck:   workerHealthcheck(worker.ID),
 			}
 
 			// set port for mastodon federated core
@@ -200,20 +217,20 @@ func WithWorkers(workers []*config.Module) Option {
 	}
 }
 
-// SetDependsOnAlloyDB would set all the rss3 node service to depend on the AlloyDB service
+// SetDependsOnAlloyDB would set all the rss3 node service to depend on the AlloyDB and Redis services,
+// skipping the ones removed from the deployment
 func SetDependsOnAlloyDB() Option {
 	return func(c *Compose) {
 		services := c.Services
 
 		for k, v := range services {
-			if strings.Contains(v.Image, "ghcr.io/rss3-network/node") {
-				v.DependsOn = map[string]DependsOn{
-					fmt.Sprintf("%s_alloydb", dockerComposeContainerNamePrefix): {
-						Condition: "service_healthy",
-					},
-					fmt.Sprintf("%s_redis", dockerComposeContainerNamePrefix): {
-						Condition: "service_healthy",
-					},
+			if strings.Contains(v.Image, NodeImage) {
+				v.DependsOn = make(map[string]DependsOn)
+
+				for _, dependency := range []string{ServiceName("alloydb"), ServiceName("redis")} {
+					if _, exists := services[dependency]; exists {
+						v.DependsOn[dependency] = DependsOn{Condition: "service_healthy"}
+					}
 				}
 
 				c.Services[k] = v
@@ -222,26 +239,143 @@ func SetDependsOnAlloyDB() Option {
 	}
 }
 
+// WithoutAlloyDB removes the database service and its volume, for nodes using an external database.
+// It must be applied before SetDependsOnAlloyDB.
+func WithoutAlloyDB() Option {
+	return func(c *Compose) {
+		removeServiceVolumes(c, ServiceName("alloydb"))
+		delete(c.Services, ServiceName("alloydb"))
+	}
+}
+
+// WithoutRedis removes the Redis service, for nodes using an external Redis.
+// It must be applied before SetDependsOnAlloyDB.
+func WithoutRedis() Option {
+	return func(c *Compose) {
+		delete(c.Services, ServiceName("redis"))
+	}
+}
+
+// SetAlloyDBPassword sets the password of the postgres user of the AlloyDB service
+func SetAlloyDBPassword(password string) Option {
+	return func(c *Compose) {
+		alloydbServiceName := ServiceName("alloydb")
+
+		service, exists := c.Services[alloydbServiceName]
+		if !exists || password == "" {
+			return
+		}
+
+		service.Environment["POSTGRES_PASSWORD"] = password
+		c.Services[alloydbServiceName] = service
+	}
+}
+
+// redisPasswordCommand starts the Redis server with the password of REDISCLI_AUTH, keeping it out of the compose command.
+// $$ escapes the interpolation of compose, the shell of the container expands the variable,
+// and the entrypoint of the image still drops the root privileges before starting the server.
+const redisPasswordCommand = `sh -c 'exec docker-entrypoint.sh redis-server --requirepass "$$REDISCLI_AUTH"'`
+
+// SetRedisPassword makes the Redis service require the password,
+// the server and redis-cli of the healthcheck read it from REDISCLI_AUTH
+func SetRedisPassword(password string) Option {
+	return func(c *Compose) {
+		redisServiceName := ServiceName("redis")
+
+		service, exists := c.Services[redisServiceName]
+		if !exists || password == "" {
+			return
+		}
+
+		service.Command = redisPasswordCommand
+		service.Environment = map[string]string{
+			"REDISCLI_AUTH": password,
+		}
+		c.Services[redisServiceName] = service
+	}
+}
+
+// AIComponent is where the node services find the AI component
+type AIComponent int
+
+const (
+	// AIComponentOff disables the AI component
+	AIComponentOff AIComponent = iota
+	// AIComponentExternal uses the endpoint of the config file
+	AIComponentExternal
+	// AIComponentLocal deploys an agentdata service alongside the node
+	AIComponentLocal
+)
+
+func (a AIComponent) String() string {
+	switch a {
+	case AIComponentExternal:
+		return "external"
+	case AIComponentLocal:
+		return "local"
+	default:
+		return "off"
+	}
+}
+
+// ParseAIComponent parses the name of an AIComponent, as returned by String
+func ParseAIComponent(name string) (AIComponent, error) {
+	for _, component := range []AIComponent{AIComponentOff, AIComponentExternal, AIComponentLocal} {
+		if component.String() == name {
+			return component, nil
+		}
+	}
+
+	return AIComponentOff, fmt.Errorf("unknown ai component %s", name)
+}
+
 // SetAIComponent configures the AI component for the node services.
-// If an external AI endpoint is provided and healthy, it's used directly.
-// If no endpoint is provided or it's unhealthy, creates an agentdata service using the existing AlloyDB.
-func SetAIComponent(cfg *config.File, isAIEndpointHealthy bool) Option {
+// It must be applied after SetAlloyDBPassword, agentdata connects with the password of the AlloyDB service,
+// or to the database server of the config file if the AlloyDB service was removed.
+// Only AIComponentLocal changes the services, it creates an agentdata service using the existing database.
+func SetAIComponent(cfg *config.File, component AIComponent) Option {
 	return func(c *Compose) {
-		// Skip if AI endpoint is healthy or component not configured
-		if isAIEndpointHealthy || cfg == nil || cfg.Component == nil || cfg.Component.AI == nil {
+		// Skip if the AI component is external, off or not configured
+		if component != AIComponentLocal || cfg == nil || cfg.Component == nil || cfg.Component.AI == nil {
 			return
 		}
 
-		// Find and validate the AlloyDB service
 		alloydbServiceName := fmt.Sprintf("%s_alloydb", dockerComposeContainerNamePrefix)
-		if _, exists := c.Services[alloydbServiceName]; !exists {
-			log.Printf("Warning: AlloyDB service %s not found, cannot set up agentdata", alloydbServiceName)
-			return
+
+		// Connect to the agent_data database of the AlloyDB service, or of the external database server
+		var connection *url.URL
+
+		dependsOn := make(map[string]DependsOn)
+
+		if alloydbService, exists := c.Services[alloydbServiceName]; exists {
+			connection = &url.URL{
+				Scheme: "postgresql",
+				User:   url.UserPassword("postgres", alloydbService.Environment["POSTGRES_PASSWORD"]),
+				Host:   alloydbServiceName + ":5432",
+			}
+
+			dependsOn[alloydbServiceName] = DependsOn{Condition: "service_healthy"}
+		} else {
+			if cfg.Database == nil || cfg.Database.URI == "" {
+				log.Printf("Warning: neither AlloyDB service %s nor database uri found, cannot set up agentdata", alloydbServiceName)
+				return
+			}
+
+			external, err := url.Parse(cfg.Database.URI)
+			if err != nil {
+				log.Printf("Warning: invalid database uri, cannot set up agentdata")
+				return
+			}
+
+			connection = external
+			connection.Scheme = "postgresql"
 		}
 
+		connection.Path = "/agent_data"
+
 		// Create base environment with database connection
 		env := map[string]string{
-			"DB_CONNECTION": fmt.Sprintf("postgresql://postgres:password@%s:5432/agent_data", alloydbServiceName),
+			"DB_CONNECTION": connection.String(),
 		}
 
 		// Extract and map AI parameters to environment variables if available
@@ -259,14 +393,13 @@ func SetAIComponent(cfg *config.File, isAIEndpointHealthy bool) Option {
 		// Create and configure the agentdata service
 		agentdataServiceName := fmt.Sprintf("%s_agentdata", dockerComposeContainerNamePrefix)
 		c.Services[agentdataServiceName] = Service{
-			Image:         "ghcr.io/rss3-network/agentdata",
+			Image:         defaultManifest.Image("agentdata").Reference(),
 			ContainerName: agentdataServiceName,
 			Restart:       "unless-stopped",
 			Ports:         []string{"8887:8887"},
 			Environment:   env,
-			DependsOn: map[string]DependsOn{
-				alloydbServiceName: {Condition: "service_healthy"},
-			},
+			DependsOn:     dependsOn,
+			Healthcheck:   agentdataHealthcheck(),
 		}
 
 		// Configure the AI endpoint for core RSS3 services
@@ -275,7 +408,7 @@ func SetAIComponent(cfg *config.File, isAIEndpointHealthy bool) Option {
 }
 
 // configureAIEndpointForCoreServices sets the AI endpoint environment variable
-// for the core, monitor, and broadcaster services only
+// for the core, monitor, and broadcaster services only, which wait for agentdata to be healthy
 func configureAIEndpointForCoreServices(c *Compose, agentdataServiceName string) {
 	// Target only these specific core services
 	coreServices := []string{
@@ -298,8 +431,13 @@ func configureAIEndpointForCoreServices(c *Compose, agentdataServiceName string)
 			service.Environment = make(map[string]string)
 		}
 
+		if service.DependsOn == nil {
+			service.DependsOn = make(map[string]DependsOn)
+		}
+
 		// Set the AI endpoint and update the service
 		service.Environment["NODE_COMPONENT_AI_ENDPOINT"] = agentdataEndpoint
+		service.DependsOn[agentdataServiceName] = DependsOn{Condition: "service_healthy"}
 		c.Services[serviceName] = service
 	}
 }
@@ -0,0 +1,159 @@
+package compose
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DatabaseBackend is the engine of the local database service.
+// Every backend runs as the rss3_node_alloydb service, so the database uri and the dependencies don't change with it.
+type DatabaseBackend interface {
+	// Image is the image of the database service, pinned to a release
+	Image() string
+	// Environment configures the image, the password of the postgres user is set by SetAlloyDBPassword
+	Environment() map[string]string
+	// Volume is the named volume keeping the data, backends have their own as their data is not compatible
+	Volume() string
+	// DataDir is where the volume is mounted
+	DataDir() string
+	Healthcheck() Healthcheck
+}
+
+// DefaultDatabaseBackend is the name of the backend used by NewCompose
+const DefaultDatabaseBackend = "alloydb"
+
+// DatabaseBackends are the supported backends by name
+var DatabaseBackends = map[string]DatabaseBackend{
+	"alloydb":     AlloyDBOmni{},
+	"postgres":    Postgres{image: "postgres", volume: "postgres"},
+	"timescaledb": Postgres{image: "timescaledb", volume: "timescaledb"},
+}
+
+// GetDatabaseBackend returns the backend with the given name
+func GetDatabaseBackend(name string) (DatabaseBackend, error) {
+	backend, ok := DatabaseBackends[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database backend %s, must be one of %s", name, strings.Join(DatabaseBackendNames(), ", "))
+	}
+
+	return backend, nil
+}
+
+// DatabaseBackendNames returns the names of the supported backends, sorted
+func DatabaseBackendNames() []string {
+	names := make([]string, 0, len(DatabaseBackends))
+	for name := range DatabaseBackends {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// AlloyDBOmni is Google's AlloyDB Omni, only published for amd64
+type AlloyDBOmni struct{}
+
+func (AlloyDBOmni) Image() string {
+	return defaultManifest.Image("alloydb").Reference()
+}
+
+func (b AlloyDBOmni) Environment() map[string]string {
+	return map[string]string{
+		"DATA_DIR":  b.DataDir(),
+		"HOST_PORT": "5432",
+	}
+}
+
+func (AlloyDBOmni) Volume() string {
+	return "alloydb"
+}
+
+func (AlloyDBOmni) DataDir() string {
+	return "/var/lib/postgresql/data"
+}
+
+func (AlloyDBOmni) Healthcheck() Healthcheck {
+	return Healthcheck{
+		Test:     []string{"CMD-SHELL", "pg_isready -U postgres"},
+		Interval: 5 * time.Second,
+		Timeout:  5 * time.Second,
+		Retries:  5,
+	}
+}
+
+// Postgres is an image based on the official postgres image, such as postgres itself or TimescaleDB
+type Postgres struct {
+	// image is the name of the image in the version manifest
+	image  string
+	volume string
+}
+
+func (b Postgres) Image() string {
+	return defaultManifest.Image(b.image).Reference()
+}
+
+func (b Postgres) Environment() map[string]string {
+	return map[string]string{
+		"PGDATA": b.DataDir(),
+	}
+}
+
+func (b Postgres) Volume() string {
+	return b.volume
+}
+
+func (Postgres) DataDir() string {
+	return "/var/lib/postgresql/data"
+}
+
+func (Postgres) Healthcheck() Healthcheck {
+	return Healthcheck{
+		Test:     []string{"CMD-SHELL", "pg_isready -U postgres"},
+		Interval: 5 * time.Second,
+		Timeout:  5 * time.Second,
+		Retries:  5,
+	}
+}
+
+// newDatabaseService returns the database service running the backend
+func newDatabaseService(backend DatabaseBackend) Service {
+	environment := backend.Environment()
+	environment["POSTGRES_PASSWORD"] = "password"
+
+	return Service{
+		ContainerName: ServiceName("alloydb"),
+		Expose:        []string{"5432"},
+		Image:         backend.Image(),
+		Volumes:       []string{fmt.Sprintf("%s:%s", backend.Volume(), backend.DataDir())},
+		Environment:   environment,
+		Healthcheck:   backend.Healthcheck(),
+	}
+}
+
+// WithDatabaseBackend runs the database service on the backend instead of AlloyDB Omni.
+// It must be applied before the options configuring the database service, such as SetAlloyDBPassword.
+func WithDatabaseBackend(backend DatabaseBackend) Option {
+	return func(c *Compose) {
+		name := ServiceName("alloydb")
+
+		if _, exists := c.Services[name]; !exists {
+			return
+		}
+
+		removeServiceVolumes(c, name)
+
+		c.Services[name] = newDatabaseService(backend)
+		c.Volumes[backend.Volume()] = nil
+	}
+}
+
+// removeServiceVolumes removes the named volumes mounted by the service
+func removeServiceVolumes(c *Compose, name string) {
+	for _, volume := range c.Services[name].Volumes {
+		source, _, _ := strings.Cut(volume, ":")
+		delete(c.Volumes, source)
+	}
+}
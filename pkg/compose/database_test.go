@@ -0,0 +1,100 @@
+package compose_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+)
+
+func TestDatabaseBackend(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name        string
+		repository  string
+		volume      string
+		environment map[string]string
+		err         string
+	}{
+		{
+			name:        "alloydb",
+			repository:  "google/alloydbomni",
+			volume:      "alloydb",
+			environment: map[string]string{"DATA_DIR": "/var/lib/postgresql/data", "HOST_PORT": "5432", "POSTGRES_PASSWORD": "secret"},
+		},
+		{
+			name:        "postgres",
+			repository:  "postgres",
+			volume:      "postgres",
+			environment: map[string]string{"PGDATA": "/var/lib/postgresql/data", "POSTGRES_PASSWORD": "secret"},
+		},
+		{
+			name:        "timescaledb",
+			repository:  "timescale/timescaledb",
+			volume:      "timescaledb",
+			environment: map[string]string{"PGDATA": "/var/lib/postgresql/data", "POSTGRES_PASSWORD": "secret"},
+		},
+		{
+			name: "mysql",
+			err:  "unsupported database backend mysql, must be one of alloydb, postgres, timescaledb",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			backend, err := compose.GetDatabaseBackend(testcase.name)
+			if testcase.err != "" {
+				if err == nil || err.Error() != testcase.err {
+					t.Fatalf("expected the error %q, got %v", testcase.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The password is set after the backend, whichever runs the database service
+			c := compose.NewCompose(compose.WithDatabaseBackend(backend), compose.SetAlloyDBPassword("secret"))
+			service := c.Services[compose.ServiceName("alloydb")]
+
+			if !strings.HasPrefix(service.Image, testcase.repository+":") {
+				t.Errorf("expected an image of %s, got %s", testcase.repository, service.Image)
+			}
+
+			if expected := []string{testcase.volume + ":/var/lib/postgresql/data"}; !reflect.DeepEqual(service.Volumes, expected) {
+				t.Errorf("expected the volumes %v, got %v", expected, service.Volumes)
+			}
+
+			if !reflect.DeepEqual(service.Environment, testcase.environment) {
+				t.Errorf("expected the environment %v, got %v", testcase.environment, service.Environment)
+			}
+
+			// Backends keep their data apart, their data directories are not compatible
+			for _, name := range compose.DatabaseBackendNames() {
+				if _, exists := c.Volumes[name]; exists != (name == testcase.volume) {
+					t.Errorf("expected the volume %s to exist: %v", name, name == testcase.volume)
+				}
+			}
+		})
+	}
+}
+
+func TestWithDatabaseBackendWithoutDatabase(t *testing.T) {
+	t.Parallel()
+
+	c := compose.NewCompose(compose.WithoutAlloyDB(), compose.WithDatabaseBackend(compose.DatabaseBackends["postgres"]))
+
+	if _, exists := c.Services[compose.ServiceName("alloydb")]; exists {
+		t.Error("expected no database service with an external database")
+	}
+
+	if _, exists := c.Volumes["postgres"]; exists {
+		t.Error("expected no database volume with an external database")
+	}
+}
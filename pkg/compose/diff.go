@@ -0,0 +1,200 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/secrets"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// maskedValue replaces environment values in diffs, they often hold credentials
+const maskedValue = "******"
+
+// ServiceDiff describes how a service differs between two compose files
+type ServiceDiff struct {
+	Service string        `json:"service"`
+	Change  string        `json:"change"`
+	Fields  []FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange describes how a single field of a service differs
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// Load reads a docker-compose file into the compose service model
+func Load(file string) (*Compose, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("load compose file, %w", err)
+	}
+
+	var c Compose
+	if err := yaml.Unmarshal(content, &c); err != nil {
+		return nil, fmt.Errorf("load compose file %s, %w", file, err)
+	}
+
+	if c.Services == nil {
+		c.Services = make(map[string]Service)
+	}
+
+	return &c, nil
+}
+
+// Diff compares two compose service models service by service, ordered by service name.
+// Environment values are masked, only the changed keys are reported,
+// and the credentials of the environment are masked in the commands and healthchecks.
+func Diff(old, new *Compose) []ServiceDiff {
+	names := make(map[string]struct{})
+
+	for name := range old.Services {
+		names[name] = struct{}{}
+	}
+
+	for name := range new.Services {
+		names[name] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+
+	sort.Strings(sorted)
+
+	var diffs []ServiceDiff
+
+	for _, name := range sorted {
+		oldService, inOld := old.Services[name]
+		newService, inNew := new.Services[name]
+
+		switch {
+		case !inOld:
+			diffs = append(diffs, ServiceDiff{Service: name, Change: ChangeAdded, Fields: diffService(Service{}, newService)})
+		case !inNew:
+			diffs = append(diffs, ServiceDiff{Service: name, Change: ChangeRemoved})
+		default:
+			if fields := diffService(oldService, newService); len(fields) > 0 {
+				diffs = append(diffs, ServiceDiff{Service: name, Change: ChangeChanged, Fields: fields})
+			}
+		}
+	}
+
+	return diffs
+}
+
+func diffService(old, new Service) []FieldChange {
+	var fields []FieldChange
+
+	// Older compose files pass credentials such as the Redis password on the command line
+	masker := secrets.NewMasker(append(environmentSecrets(old), environmentSecrets(new)...)...)
+
+	compare := func(field, oldValue, newValue string) {
+		if oldValue != newValue {
+			fields = append(fields, FieldChange{Field: field, Old: masker.Mask(oldValue), New: masker.Mask(newValue)})
+		}
+	}
+
+	compare("image", old.Image, new.Image)
+	compare("command", old.Command, new.Command)
+	compare("restart", old.Restart, new.Restart)
+	compare("ports", strings.Join(old.Ports, ", "), strings.Join(new.Ports, ", "))
+	compare("expose", strings.Join(old.Expose, ", "), strings.Join(new.Expose, ", "))
+	compare("volumes", strings.Join(old.Volumes, ", "), strings.Join(new.Volumes, ", "))
+	compare("depends_on", formatDependsOn(old.DependsOn), formatDependsOn(new.DependsOn))
+	compare("healthcheck", formatHealthcheck(old.Healthcheck), formatHealthcheck(new.Healthcheck))
+	compare("resources", formatResources(old), formatResources(new))
+
+	keys := make(map[string]struct{})
+
+	for key := range old.Environment {
+		keys[key] = struct{}{}
+	}
+
+	for key := range new.Environment {
+		keys[key] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		oldValue, inOld := old.Environment[key]
+		newValue, inNew := new.Environment[key]
+
+		if inOld && inNew && oldValue == newValue {
+			continue
+		}
+
+		change := FieldChange{Field: "environment." + key}
+
+		if inOld {
+			change.Old = maskedValue
+		}
+
+		if inNew {
+			change.New = maskedValue
+		}
+
+		fields = append(fields, change)
+	}
+
+	return fields
+}
+
+// environmentSecrets returns the credentials of the service environment
+func environmentSecrets(service Service) []string {
+	keys := sortedSecretKeys(service.Environment)
+	values := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		values = append(values, service.Environment[key])
+	}
+
+	return values
+}
+
+func formatDependsOn(dependsOn map[string]DependsOn) string {
+	dependencies := make([]string, 0, len(dependsOn))
+
+	for name, dependency := range dependsOn {
+		if dependency.Condition != "" {
+			name = fmt.Sprintf("%s (%s)", name, dependency.Condition)
+		}
+
+		dependencies = append(dependencies, name)
+	}
+
+	sort.Strings(dependencies)
+
+	return strings.Join(dependencies, ", ")
+}
+
+func formatHealthcheck(healthcheck Healthcheck) string {
+	if len(healthcheck.Test) == 0 {
+		return ""
+	}
+
+	formatted := fmt.Sprintf("%s every %s, timeout %s, %d retries", strings.Join(healthcheck.Test, " "), healthcheck.Interval, healthcheck.Timeout, healthcheck.Retries)
+
+	if healthcheck.StartPeriod > 0 {
+		formatted += fmt.Sprintf(", start period %s", healthcheck.StartPeriod)
+	}
+
+	return formatted
+}
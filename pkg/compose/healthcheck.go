@@ -0,0 +1,62 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AgentdataHealthPath is the health endpoint of the agentdata API
+const AgentdataHealthPath = "/api/v1/health"
+
+// HealthyWorkerStatuses are the worker statuses reported by the core API of a working worker
+var HealthyWorkerStatuses = []string{"Indexing", "Ready"}
+
+// coreHealthcheck probes the API of the core service, wget is part of the node image
+func coreHealthcheck() Healthcheck {
+	return Healthcheck{
+		Test:        []string{"CMD", "wget", "-q", "-O", "/dev/null", "http://127.0.0.1:80/operators/info"},
+		Interval:    10 * time.Second,
+		Timeout:     5 * time.Second,
+		Retries:     5,
+		StartPeriod: 30 * time.Second,
+	}
+}
+
+// moduleHealthcheck checks the node runs the module, the monitor and the broadcaster serve no API to probe
+func moduleHealthcheck(module string) Healthcheck {
+	return Healthcheck{
+		Test:     []string{"CMD-SHELL", fmt.Sprintf("grep -q -- --module=%s /proc/1/cmdline", module)},
+		Interval: 30 * time.Second,
+		Timeout:  5 * time.Second,
+		Retries:  3,
+	}
+}
+
+// workerHealthcheck checks the status of the worker reported by the core API, which the monitor keeps up to date.
+// The status is unknown until the monitor checked the worker once, hence the long start period.
+func workerHealthcheck(id string) Healthcheck {
+	status := fmt.Sprintf(`"worker_id":"%s"[^}]*"status":"(%s)"`, id, strings.Join(HealthyWorkerStatuses, "|"))
+
+	return Healthcheck{
+		Test: []string{"CMD-SHELL", fmt.Sprintf("wget -q -O - http://%s:80/operators/workers_status | tr -d ' \\n' | grep -Eq '%s'",
+			ServiceName("core"), status)},
+		Interval:    30 * time.Second,
+		Timeout:     10 * time.Second,
+		Retries:     3,
+		StartPeriod: 5 * time.Minute,
+	}
+}
+
+// agentdataHealthcheck probes the health endpoint of the agentdata API, the same one the deployer probes on external endpoints.
+// agentdata is a Python service built on a python image, which has neither wget nor curl, so python3 sends the request.
+func agentdataHealthcheck() Healthcheck {
+	return Healthcheck{
+		Test: []string{"CMD", "python3", "-c",
+			fmt.Sprintf("import urllib.request; urllib.request.urlopen('http://127.0.0.1:8887%s', timeout=4)", AgentdataHealthPath)},
+		Interval:    10 * time.Second,
+		Timeout:     5 * time.Second,
+		Retries:     5,
+		StartPeriod: 30 * time.Second,
+	}
+}
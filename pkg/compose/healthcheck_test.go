@@ -0,0 +1,140 @@
+package compose_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node/v2/config"
+	"github.com/rss3-network/node/v2/schema/worker/decentralized"
+	"github.com/rss3-network/protocol-go/schema/network"
+)
+
+// workersStatus is a response of /operators/workers_status, indented like the core API does
+const workersStatus = `{
+  "data": {
+    "decentralized": [
+      {
+        "network": "arbitrum",
+        "worker": "core",
+        "worker_id": "arbitrum-core",
+        "tags": ["transaction"],
+        "status": "Ready"
+      },
+      {
+        "network": "ethereum",
+        "worker": "core",
+        "worker_id": "ethereum-core",
+        "tags": ["transaction"],
+        "status": "%s",
+        "remote_state": 21000000,
+        "indexed_state": 20000000
+      }
+    ]
+  }
+}`
+
+// TestHealthchecks runs the healthcheck commands of the services against a fake core and agentdata API
+func TestHealthchecks(t *testing.T) {
+	t.Parallel()
+
+	workers := []*config.Module{
+		{ID: "arbitrum-core", Network: network.Arbitrum, Worker: decentralized.Core},
+		{ID: "ethereum-core", Network: network.Ethereum, Worker: decentralized.Core},
+	}
+
+	cfg := &config.File{Component: &config.Component{AI: &config.Module{ID: "ai"}}}
+	c := compose.NewCompose(compose.WithWorkers(workers), compose.SetAIComponent(cfg, compose.AIComponentLocal))
+
+	testcases := []struct {
+		name     string
+		service  string
+		path     string
+		status   int
+		body     string
+		expected bool
+	}{
+		{name: "core serving", service: compose.ServiceName("core"), path: "/operators/info", status: http.StatusOK, expected: true},
+		{name: "core failing", service: compose.ServiceName("core"), path: "/operators/info", status: http.StatusServiceUnavailable},
+		{name: "worker ready", service: compose.WorkerServiceName("ethereum-core"), path: "/operators/workers_status", status: http.StatusOK, body: "Ready", expected: true},
+		{name: "worker indexing", service: compose.WorkerServiceName("ethereum-core"), path: "/operators/workers_status", status: http.StatusOK, body: "Indexing", expected: true},
+		// Another worker being ready doesn't make this one healthy
+		{name: "worker unhealthy", service: compose.WorkerServiceName("ethereum-core"), path: "/operators/workers_status", status: http.StatusOK, body: "Unhealthy"},
+		{name: "core api unreachable", service: compose.WorkerServiceName("ethereum-core"), path: "/operators/workers_status", status: http.StatusBadGateway},
+		{name: "agentdata healthy", service: compose.ServiceName("agentdata"), path: compose.AgentdataHealthPath, status: http.StatusOK, expected: true},
+		{name: "agentdata unhealthy", service: compose.ServiceName("agentdata"), path: compose.AgentdataHealthPath, status: http.StatusInternalServerError},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != testcase.path {
+					http.NotFound(w, r)
+
+					return
+				}
+
+				w.WriteHeader(testcase.status)
+
+				if testcase.body != "" {
+					_, _ = w.Write([]byte(strings.Replace(workersStatus, "%s", testcase.body, 1)))
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			service, ok := c.Services[testcase.service]
+			if !ok {
+				t.Fatalf("expected the service %s", testcase.service)
+			}
+
+			// The commands reach the APIs on the addresses of the containers, the fake one listens on the loopback
+			replacer := strings.NewReplacer(
+				"http://127.0.0.1:80/", server.URL+"/",
+				"http://127.0.0.1:8887/", server.URL+"/",
+				"http://"+compose.ServiceName("core")+":80/", server.URL+"/",
+			)
+
+			test := service.Healthcheck.Test
+
+			var args []string
+
+			switch test[0] {
+			case "CMD":
+				args = slices.Clone(test[1:])
+			case "CMD-SHELL":
+				args = []string{"sh", "-c", test[1]}
+			default:
+				t.Fatalf("unsupported healthcheck %q", test)
+			}
+
+			for i := range args {
+				args[i] = replacer.Replace(args[i])
+			}
+
+			tool := strings.Fields(args[len(args)-1])[0]
+			if test[0] == "CMD" {
+				tool = args[0]
+			}
+
+			if _, err := exec.LookPath(tool); err != nil {
+				t.Skipf("%s is not installed", tool)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), service.Healthcheck.Timeout+5*time.Second)
+			defer cancel()
+
+			output, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+			if healthy := err == nil; healthy != testcase.expected {
+				t.Errorf("expected %s to be healthy: %v, got %v, %s", testcase.service, testcase.expected, err, output)
+			}
+		})
+	}
+}
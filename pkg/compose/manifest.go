@@ -0,0 +1,203 @@
+package compose
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+//go:embed manifest.yaml
+var embeddedManifest []byte
+
+// Image is an image of the deployment, pinned by an exact tag and its digest
+type Image struct {
+	Repository string `yaml:"repository" json:"repository"`
+	Tag        string `yaml:"tag" json:"tag"`
+	Digest     string `yaml:"digest,omitempty" json:"digest,omitempty"`
+}
+
+// Reference returns the image reference used in the compose file, e.g. redis:7.4.1-alpine@sha256:...
+func (i Image) Reference() string {
+	reference := i.Repository
+
+	if i.Tag != "" {
+		reference += ":" + i.Tag
+	}
+
+	if i.Digest != "" {
+		reference += "@" + i.Digest
+	}
+
+	return reference
+}
+
+// Manifest lists the images deployed by a release of the deployer
+type Manifest struct {
+	Release string           `yaml:"release" json:"release"`
+	Images  map[string]Image `yaml:"images" json:"images"`
+}
+
+// ManifestFile is the format of the version manifest, the newest release first
+type ManifestFile struct {
+	Releases []Manifest `yaml:"releases" json:"releases"`
+}
+
+// defaultManifest is the manifest of the newest release in the embedded manifest, used by NewCompose.
+// The deployed images are the ones of LoadManifestFile, which checks they are pinned.
+var defaultManifest = func() *Manifest {
+	manifestFile, err := parseManifestFile(embeddedManifest)
+	if err != nil {
+		panic(fmt.Sprintf("parse embedded manifest, %v", err))
+	}
+
+	return manifestFile.Select("")
+}()
+
+var (
+	// immutableTagPattern matches the tags naming a release, e.g. v2.0.0, 15.10 or 7.4.1-alpine, rather than a moving one such as latest
+	immutableTagPattern = regexp.MustCompile(`^v?\d+\.\d+`)
+	digestPattern       = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+)
+
+// IsMutableTag reports whether a tag can be moved to another image, such as latest or a major version
+func IsMutableTag(tag string) bool {
+	return !immutableTagPattern.MatchString(tag)
+}
+
+// ParseManifestFile parses a version manifest, every image must be pinned by an exact tag and its digest
+func ParseManifestFile(content []byte) (*ManifestFile, error) {
+	manifestFile, err := parseManifestFile(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := manifestFile.CheckPinned(); err != nil {
+		return nil, err
+	}
+
+	return manifestFile, nil
+}
+
+func parseManifestFile(content []byte) (*ManifestFile, error) {
+	var manifestFile ManifestFile
+	if err := yaml.Unmarshal(content, &manifestFile); err != nil {
+		return nil, fmt.Errorf("parse version manifest, %w", err)
+	}
+
+	if len(manifestFile.Releases) == 0 {
+		return nil, fmt.Errorf("version manifest has no release")
+	}
+
+	for _, manifest := range manifestFile.Releases {
+		if node := manifest.Image("node"); node.Repository == "" || node.Tag == "" {
+			return nil, fmt.Errorf("release %s of the version manifest has no node image", manifest.Release)
+		}
+	}
+
+	return &manifestFile, nil
+}
+
+// CheckPinned returns the images of every release which are not pinned by an exact tag and its digest
+func (f *ManifestFile) CheckPinned() error {
+	var errs []error
+
+	for _, manifest := range f.Releases {
+		names := make([]string, 0, len(manifest.Images))
+		for name := range manifest.Images {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			image := manifest.Images[name]
+
+			if IsMutableTag(image.Tag) {
+				errs = append(errs, fmt.Errorf("release %s of the version manifest has the mutable tag %q for %s", manifest.Release, image.Tag, name))
+			}
+
+			if !digestPattern.MatchString(image.Digest) {
+				errs = append(errs, fmt.Errorf("release %s of the version manifest has no sha256 digest for %s", manifest.Release, name))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// LoadManifestFile reads the version manifest from a file, or the embedded one if file is empty.
+// Unless allowUnpinned is set, every image must be pinned by an exact tag and its digest.
+func LoadManifestFile(file string, allowUnpinned bool) (*ManifestFile, error) {
+	content := embeddedManifest
+
+	if file != "" {
+		var err error
+
+		if content, err = os.ReadFile(file); err != nil {
+			return nil, fmt.Errorf("read version manifest, %w", err)
+		}
+	}
+
+	if allowUnpinned {
+		return parseManifestFile(content)
+	}
+
+	return ParseManifestFile(content)
+}
+
+// Select returns the manifest of the release, or of the newest release if it has no entry
+func (f *ManifestFile) Select(release string) *Manifest {
+	for i := range f.Releases {
+		if f.Releases[i].Release == release {
+			return &f.Releases[i]
+		}
+	}
+
+	return &f.Releases[0]
+}
+
+// Image returns the image with the given name, e.g. node or redis
+func (m *Manifest) Image(name string) Image {
+	return m.Images[name]
+}
+
+// WithImages pins the image of every service to the one of the manifest with the same repository.
+// Node services keep a version set by SetNodeVersion which differs from the manifest, so it must be applied last.
+func WithImages(m *Manifest) Option {
+	return func(c *Compose) {
+		images := make(map[string]Image, len(m.Images))
+		for _, image := range m.Images {
+			images[image.Repository] = image
+		}
+
+		for name, service := range c.Services {
+			repository, tag := splitImage(service.Image)
+
+			image, ok := images[repository]
+			if !ok || (tag != "" && tag != image.Tag && repository == NodeImage) {
+				continue
+			}
+
+			service.Image = image.Reference()
+			c.Services[name] = service
+		}
+	}
+}
+
+// splitImage returns the repository and the tag of an image reference
+func splitImage(reference string) (string, string) {
+	reference, _, _ = strings.Cut(reference, "@")
+
+	// A colon after the last slash separates the tag, others belong to the registry host
+	if i := strings.LastIndex(reference, ":"); i > strings.LastIndex(reference, "/") {
+		return reference[:i], reference[i+1:]
+	}
+
+	return reference, ""
+}
@@ -0,0 +1,93 @@
+package compose_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+)
+
+func TestParseManifestFile(t *testing.T) {
+	t.Parallel()
+
+	digest := "sha256:" + strings.Repeat("0", 64)
+
+	testcases := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{
+			name: "pinned",
+			content: `releases:
+  - release: v0.6.2
+    images:
+      node: {repository: ghcr.io/rss3-network/node, tag: v2.0.0, digest: "` + digest + `"}
+      postgres: {repository: postgres, tag: "15.10", digest: "` + digest + `"}
+`,
+		},
+		{
+			name: "without digest",
+			content: `releases:
+  - release: v0.6.2
+    images:
+      node: {repository: ghcr.io/rss3-network/node, tag: v2.0.0}
+`,
+			err: "release v0.6.2 of the version manifest has no sha256 digest for node",
+		},
+		{
+			name: "invalid digest",
+			content: `releases:
+  - release: v0.6.2
+    images:
+      node: {repository: ghcr.io/rss3-network/node, tag: v2.0.0, digest: "sha256:abc"}
+`,
+			err: "release v0.6.2 of the version manifest has no sha256 digest for node",
+		},
+		{
+			name: "mutable tags",
+			content: `releases:
+  - release: v0.6.2
+    images:
+      node: {repository: ghcr.io/rss3-network/node, tag: v2.0.0, digest: "` + digest + `"}
+      agentdata: {repository: ghcr.io/rss3-network/agentdata, tag: latest, digest: "` + digest + `"}
+      postgres: {repository: postgres, tag: "15", digest: "` + digest + `"}
+`,
+			err: "release v0.6.2 of the version manifest has the mutable tag \"latest\" for agentdata\n" +
+				"release v0.6.2 of the version manifest has the mutable tag \"15\" for postgres",
+		},
+		{
+			name:    "without node image",
+			content: "releases:\n  - release: v0.6.2\n    images: {}\n",
+			err:     "release v0.6.2 of the version manifest has no node image",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := compose.ParseManifestFile([]byte(testcase.content))
+			if testcase.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != testcase.err {
+				t.Errorf("expected the error %q, got %v", testcase.err, err)
+			}
+		})
+	}
+}
+
+func TestLoadManifestFileUnpinned(t *testing.T) {
+	t.Parallel()
+
+	// Unpinned images are read on request, the embedded manifest is pinned only when a release is made
+	if _, err := compose.LoadManifestFile("", true); err != nil {
+		t.Fatal(err)
+	}
+}
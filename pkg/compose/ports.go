@@ -0,0 +1,205 @@
+package compose
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PublishedPort is a port of a service published on the host, in the compose short syntax [host_ip:]host_port:container_port
+type PublishedPort struct {
+	HostIP        string
+	HostPort      int
+	ContainerPort int
+}
+
+// ParsePublishedPort parses a published port such as 8080:80, 127.0.0.1:8080:80 or [::1]:8080:80
+func ParsePublishedPort(published string) (PublishedPort, error) {
+	var port PublishedPort
+
+	rest := published
+
+	// An IPv6 host address is enclosed in brackets, its colons don't separate the ports
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]:")
+		if end < 0 {
+			return port, fmt.Errorf("invalid published port %s", published)
+		}
+
+		port.HostIP, rest = rest[1:end], rest[end+2:]
+	}
+
+	parts := strings.Split(rest, ":")
+	if len(parts) > 3 || (port.HostIP != "" && len(parts) != 2) {
+		return port, fmt.Errorf("invalid published port %s", published)
+	}
+
+	if len(parts) == 3 {
+		port.HostIP, parts = parts[0], parts[1:]
+	}
+
+	var err error
+
+	if port.ContainerPort, err = strconv.Atoi(parts[len(parts)-1]); err != nil {
+		return port, fmt.Errorf("invalid published port %s, %w", published, err)
+	}
+
+	port.HostPort = port.ContainerPort
+
+	if len(parts) == 2 {
+		if port.HostPort, err = strconv.Atoi(parts[0]); err != nil {
+			return port, fmt.Errorf("invalid published port %s, %w", published, err)
+		}
+	}
+
+	return port, nil
+}
+
+// String returns the port in the compose short syntax
+func (p PublishedPort) String() string {
+	switch {
+	case p.HostIP == "":
+		return fmt.Sprintf("%d:%d", p.HostPort, p.ContainerPort)
+	case strings.Contains(p.HostIP, ":"):
+		return fmt.Sprintf("[%s]:%d:%d", p.HostIP, p.HostPort, p.ContainerPort)
+	default:
+		return fmt.Sprintf("%s:%d:%d", p.HostIP, p.HostPort, p.ContainerPort)
+	}
+}
+
+// Address returns the host address the port is published on, all the interfaces if no host IP is set
+func (p PublishedPort) Address() string {
+	return net.JoinHostPort(p.HostIP, strconv.Itoa(p.HostPort))
+}
+
+// Overlaps reports whether both ports can't be published at the same time, they have the same host port
+// and the same host IP, or one of them is published on all the interfaces
+func (p PublishedPort) Overlaps(other PublishedPort) bool {
+	if p.HostPort != other.HostPort {
+		return false
+	}
+
+	if isWildcardIP(p.HostIP) || isWildcardIP(other.HostIP) {
+		return true
+	}
+
+	return net.ParseIP(p.HostIP).Equal(net.ParseIP(other.HostIP)) || p.HostIP == other.HostIP
+}
+
+// isWildcardIP reports whether a host IP stands for all the interfaces, no IP, 0.0.0.0 or ::
+func isWildcardIP(hostIP string) bool {
+	return hostIP == "" || net.ParseIP(hostIP).IsUnspecified()
+}
+
+// WithHostPorts publishes the ports of the services on the host ports given by service name and container port.
+// It must be applied after the options adding services, and before WithPortBindings whose host ports take precedence.
+func WithHostPorts(hostPorts map[string]map[int]int) Option {
+	return func(c *Compose) {
+		for name, service := range c.Services {
+			ports, err := service.PublishedPorts()
+			if err != nil || len(ports) == 0 || len(hostPorts[name]) == 0 {
+				continue
+			}
+
+			for i := range ports {
+				if hostPort, ok := hostPorts[name][ports[i].ContainerPort]; ok {
+					ports[i].HostPort = hostPort
+				}
+			}
+
+			service.SetPublishedPorts(ports)
+			c.Services[name] = service
+		}
+	}
+}
+
+// PortBinding is where the published port of a service is bound on the host, zero values keep the default
+type PortBinding struct {
+	HostIP   string
+	HostPort int
+}
+
+// ParsePortBinding parses a host port, a host IP or both, e.g. 8081, 127.0.0.1, 127.0.0.1:8081 or [::1]:8081
+func ParsePortBinding(value string) (PortBinding, error) {
+	var binding PortBinding
+
+	if port, err := strconv.Atoi(value); err == nil {
+		binding.HostPort = port
+	} else if ip := net.ParseIP(strings.Trim(value, "[]")); ip != nil {
+		binding.HostIP = ip.String()
+	} else {
+		host, port, err := net.SplitHostPort(value)
+		if err != nil {
+			return binding, fmt.Errorf("invalid port binding %s, must be [host_ip:]host_port", value)
+		}
+
+		if net.ParseIP(host) == nil {
+			return binding, fmt.Errorf("invalid port binding %s, %s is not an IP address", value, host)
+		}
+
+		if binding.HostPort, err = strconv.Atoi(port); err != nil {
+			return binding, fmt.Errorf("invalid port binding %s, %w", value, err)
+		}
+
+		binding.HostIP = net.ParseIP(host).String()
+	}
+
+	if binding.HostPort < 0 || binding.HostPort > 65535 {
+		return binding, fmt.Errorf("invalid port binding %s, port out of range", value)
+	}
+
+	return binding, nil
+}
+
+// PublishedPorts returns the published ports of a service
+func (s Service) PublishedPorts() ([]PublishedPort, error) {
+	ports := make([]PublishedPort, 0, len(s.Ports))
+
+	for _, published := range s.Ports {
+		port, err := ParsePublishedPort(published)
+		if err != nil {
+			return nil, err
+		}
+
+		ports = append(ports, port)
+	}
+
+	return ports, nil
+}
+
+// SetPublishedPorts replaces the published ports of a service
+func (s *Service) SetPublishedPorts(ports []PublishedPort) {
+	s.Ports = make([]string, 0, len(ports))
+
+	for _, port := range ports {
+		s.Ports = append(s.Ports, port.String())
+	}
+}
+
+// WithPortBindings binds the published ports of the services to hostIP, or to the host IP and port of their binding.
+// It must be applied after the options adding services, such as WithWorkers and SetAIComponent.
+func WithPortBindings(hostIP string, bindings map[string]PortBinding) Option {
+	return func(c *Compose) {
+		for name, service := range c.Services {
+			ports, err := service.PublishedPorts()
+			if err != nil || len(ports) == 0 {
+				continue
+			}
+
+			binding := bindings[name]
+
+			for i := range ports {
+				ports[i].HostIP = cmp.Or(binding.HostIP, hostIP, ports[i].HostIP)
+
+				if binding.HostPort > 0 {
+					ports[i].HostPort = binding.HostPort
+				}
+			}
+
+			service.SetPublishedPorts(ports)
+			c.Services[name] = service
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package compose_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+)
+
+func TestPublishedPortOverlaps(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "8080:80", b: "8080:8080", expected: true},
+		{a: "8080:80", b: "8081:80", expected: false},
+		{a: "127.0.0.1:8080:80", b: "127.0.0.1:8080:80", expected: true},
+		{a: "127.0.0.1:8080:80", b: "192.168.1.10:8080:80", expected: false},
+		{a: "127.0.0.1:8080:80", b: "8080:80", expected: true},
+		{a: "0.0.0.0:8080:80", b: "127.0.0.1:8080:80", expected: true},
+		{a: "[::]:8080:80", b: "[::1]:8080:80", expected: true},
+		{a: "[::1]:8080:80", b: "[0:0::1]:8080:80", expected: true},
+		{a: "[::1]:8080:80", b: "127.0.0.1:8080:80", expected: false},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.a+" "+testcase.b, func(t *testing.T) {
+			t.Parallel()
+
+			a, err := compose.ParsePublishedPort(testcase.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := compose.ParsePublishedPort(testcase.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if a.Overlaps(b) != testcase.expected || b.Overlaps(a) != testcase.expected {
+				t.Errorf("expected overlap %v", testcase.expected)
+			}
+		})
+	}
+}
+
+func TestWithHostPorts(t *testing.T) {
+	t.Parallel()
+
+	c := &compose.Compose{Services: map[string]compose.Service{
+		compose.ServiceName("core"):                {Ports: []string{"8080:80"}},
+		compose.WorkerServiceName("mastodon-core"): {Ports: []string{"127.0.0.1:8181:8181", "9090:9090"}},
+		compose.ServiceName("monitor"):             {},
+	}}
+
+	compose.WithHostPorts(map[string]map[int]int{
+		compose.ServiceName("core"):                {80: 8081},
+		compose.WorkerServiceName("mastodon-core"): {8181: 8182, 9090: 9091},
+		compose.ServiceName("monitor"):             {80: 8083},
+	})(c)
+
+	// Another binding given afterwards takes precedence
+	compose.WithPortBindings("", map[string]compose.PortBinding{compose.ServiceName("core"): {HostPort: 8084}})(c)
+
+	expected := map[string][]string{
+		compose.ServiceName("core"):                {"8084:80"},
+		compose.WorkerServiceName("mastodon-core"): {"127.0.0.1:8182:8181", "9091:9090"},
+		compose.ServiceName("monitor"):             nil,
+	}
+
+	for name, ports := range expected {
+		if actual := c.Services[name].Ports; !reflect.DeepEqual(actual, ports) {
+			t.Errorf("expected the ports %q of %s, got %q", ports, name, actual)
+		}
+	}
+}
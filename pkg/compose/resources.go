@@ -0,0 +1,305 @@
+package compose
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rss3-network/node/v2/config"
+)
+
+type Deploy struct {
+	Resources Resources `yaml:"resources,omitempty"`
+}
+
+type Resources struct {
+	Limits       *ResourceLimits `yaml:"limits,omitempty"`
+	Reservations *ResourceLimits `yaml:"reservations,omitempty"`
+}
+
+type ResourceLimits struct {
+	CPUs   float64 `yaml:"cpus,omitempty"`
+	Memory string  `yaml:"memory,omitempty"`
+}
+
+type Ulimit struct {
+	Soft int `yaml:"soft" json:"soft"`
+	Hard int `yaml:"hard" json:"hard"`
+}
+
+// ResourceSpec is the resources of a class of services, memory sizes use the compose format, e.g. 512m or 2g
+type ResourceSpec struct {
+	CPUs         float64           `yaml:"cpus,omitempty"`
+	Memory       string            `yaml:"memory,omitempty"`
+	Reservations ResourceLimits    `yaml:"reservations,omitempty"`
+	ShmSize      string            `yaml:"shm_size,omitempty"`
+	Ulimits      map[string]Ulimit `yaml:"ulimits,omitempty"`
+}
+
+// ResourceConfig is the resources of every class of services, workers use the resources of their network if set
+type ResourceConfig struct {
+	Database    ResourceSpec            `yaml:"database,omitempty"`
+	Redis       ResourceSpec            `yaml:"redis,omitempty"`
+	Core        ResourceSpec            `yaml:"core,omitempty"`
+	Monitor     ResourceSpec            `yaml:"monitor,omitempty"`
+	Broadcaster ResourceSpec            `yaml:"broadcaster,omitempty"`
+	Agentdata   ResourceSpec            `yaml:"agentdata,omitempty"`
+	Worker      ResourceSpec            `yaml:"worker,omitempty"`
+	Networks    map[string]ResourceSpec `yaml:"networks,omitempty"`
+}
+
+// DefaultResources keeps a busy worker from starving the database, the database gets a larger /dev/shm for parallel queries
+var DefaultResources = ResourceConfig{
+	Database: ResourceSpec{
+		CPUs:         4,
+		Memory:       "8g",
+		Reservations: ResourceLimits{CPUs: 1, Memory: "2g"},
+		ShmSize:      "1g",
+		Ulimits:      map[string]Ulimit{"nofile": {Soft: 65536, Hard: 65536}},
+	},
+	Redis: ResourceSpec{
+		CPUs:         1,
+		Memory:       "1g",
+		Reservations: ResourceLimits{Memory: "128m"},
+	},
+	Core: ResourceSpec{
+		CPUs:         2,
+		Memory:       "2g",
+		Reservations: ResourceLimits{Memory: "256m"},
+	},
+	Monitor: ResourceSpec{
+		CPUs:   0.5,
+		Memory: "512m",
+	},
+	Broadcaster: ResourceSpec{
+		CPUs:   0.5,
+		Memory: "512m",
+	},
+	Agentdata: ResourceSpec{
+		CPUs:         2,
+		Memory:       "2g",
+		Reservations: ResourceLimits{Memory: "256m"},
+	},
+	Worker: ResourceSpec{
+		CPUs:         1,
+		Memory:       "1g",
+		Reservations: ResourceLimits{Memory: "128m"},
+	},
+	Networks: map[string]ResourceSpec{
+		// The busiest networks index the most activities
+		"ethereum": {CPUs: 2, Memory: "2g"},
+	},
+}
+
+// Merge returns the resources with the values set in override replacing the ones of r
+func (r ResourceConfig) Merge(override ResourceConfig) ResourceConfig {
+	merged := ResourceConfig{
+		Database:    r.Database.Merge(override.Database),
+		Redis:       r.Redis.Merge(override.Redis),
+		Core:        r.Core.Merge(override.Core),
+		Monitor:     r.Monitor.Merge(override.Monitor),
+		Broadcaster: r.Broadcaster.Merge(override.Broadcaster),
+		Agentdata:   r.Agentdata.Merge(override.Agentdata),
+		Worker:      r.Worker.Merge(override.Worker),
+		Networks:    make(map[string]ResourceSpec, len(r.Networks)+len(override.Networks)),
+	}
+
+	for name, spec := range r.Networks {
+		merged.Networks[name] = spec
+	}
+
+	for name, spec := range override.Networks {
+		merged.Networks[name] = merged.Networks[name].Merge(spec)
+	}
+
+	return merged
+}
+
+// CapCPUs lowers the CPU limits and reservations above n, docker rejects more CPUs than the host has
+func (r ResourceConfig) CapCPUs(n float64) ResourceConfig {
+	capped := r
+	for _, spec := range []*ResourceSpec{&capped.Database, &capped.Redis, &capped.Core, &capped.Monitor, &capped.Broadcaster, &capped.Agentdata, &capped.Worker} {
+		spec.CPUs, spec.Reservations.CPUs = min(spec.CPUs, n), min(spec.Reservations.CPUs, n)
+	}
+
+	capped.Networks = make(map[string]ResourceSpec, len(r.Networks))
+
+	for name, spec := range r.Networks {
+		spec.CPUs, spec.Reservations.CPUs = min(spec.CPUs, n), min(spec.Reservations.CPUs, n)
+		capped.Networks[name] = spec
+	}
+
+	return capped
+}
+
+// Validate checks the memory sizes of every class
+func (r ResourceConfig) Validate() error {
+	specs := map[string]ResourceSpec{
+		"database":    r.Database,
+		"redis":       r.Redis,
+		"core":        r.Core,
+		"monitor":     r.Monitor,
+		"broadcaster": r.Broadcaster,
+		"agentdata":   r.Agentdata,
+		"worker":      r.Worker,
+	}
+
+	for name, spec := range r.Networks {
+		specs["networks."+name] = spec
+	}
+
+	for name, spec := range specs {
+		for _, size := range []string{spec.Memory, spec.Reservations.Memory, spec.ShmSize} {
+			if _, err := ParseMemory(size); size != "" && err != nil {
+				return fmt.Errorf("invalid resources of %s, %w", name, err)
+			}
+		}
+
+		if spec.CPUs < 0 || spec.Reservations.CPUs < 0 {
+			return fmt.Errorf("invalid resources of %s, cpus must be positive", name)
+		}
+	}
+
+	return nil
+}
+
+// Merge returns the spec with the values set in override replacing the ones of s
+func (s ResourceSpec) Merge(override ResourceSpec) ResourceSpec {
+	if override.CPUs != 0 {
+		s.CPUs = override.CPUs
+	}
+
+	if override.Memory != "" {
+		s.Memory = override.Memory
+	}
+
+	if override.Reservations.CPUs != 0 {
+		s.Reservations.CPUs = override.Reservations.CPUs
+	}
+
+	if override.Reservations.Memory != "" {
+		s.Reservations.Memory = override.Reservations.Memory
+	}
+
+	if override.ShmSize != "" {
+		s.ShmSize = override.ShmSize
+	}
+
+	if len(override.Ulimits) > 0 {
+		ulimits := make(map[string]Ulimit, len(s.Ulimits)+len(override.Ulimits))
+
+		for name, ulimit := range s.Ulimits {
+			ulimits[name] = ulimit
+		}
+
+		for name, ulimit := range override.Ulimits {
+			ulimits[name] = ulimit
+		}
+
+		s.Ulimits = ulimits
+	}
+
+	return s
+}
+
+// apply sets the resources of the service, both as deploy.resources and as the mem_limit and cpus understood
+// by the standalone docker-compose, which ignores the deploy section
+func (s ResourceSpec) apply(service *Service) {
+	service.Deploy = nil
+	service.MemLimit, service.CPUs = s.Memory, s.CPUs
+	service.ShmSize, service.Ulimits = s.ShmSize, s.Ulimits
+
+	var resources Resources
+
+	if s.CPUs != 0 || s.Memory != "" {
+		resources.Limits = &ResourceLimits{CPUs: s.CPUs, Memory: s.Memory}
+	}
+
+	if s.Reservations != (ResourceLimits{}) {
+		reservations := s.Reservations
+		resources.Reservations = &reservations
+	}
+
+	if resources != (Resources{}) {
+		service.Deploy = &Deploy{Resources: resources}
+	}
+}
+
+// WithResources sets the resources of the services by their class, and of the workers by their network.
+// It must be applied after the options adding services, such as WithWorkers and SetAIComponent.
+func WithResources(resources ResourceConfig, workers []*config.Module) Option {
+	return func(c *Compose) {
+		specs := map[string]ResourceSpec{
+			ServiceName("alloydb"):     resources.Database,
+			ServiceName("redis"):       resources.Redis,
+			ServiceName("core"):        resources.Core,
+			ServiceName("monitor"):     resources.Monitor,
+			ServiceName("broadcaster"): resources.Broadcaster,
+			ServiceName("agentdata"):   resources.Agentdata,
+		}
+
+		for _, worker := range workers {
+			specs[WorkerServiceName(worker.ID)] = resources.Worker.Merge(resources.Networks[worker.Network.String()])
+		}
+
+		for name, service := range c.Services {
+			if spec, ok := specs[name]; ok {
+				spec.apply(&service)
+				c.Services[name] = service
+			}
+		}
+	}
+}
+
+// memoryPattern matches the byte sizes of compose, e.g. 512m, 2g or 1.5gb
+var memoryPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([bkmg]?)b?$`)
+
+// ParseMemory parses a compose byte size, the units are powers of 1024
+func ParseMemory(size string) (int64, error) {
+	match := memoryPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(size)))
+	if match == nil {
+		return 0, fmt.Errorf("invalid memory size %q, must be a number of bytes with an optional unit b, k, m or g", size)
+	}
+
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory size %q, %w", size, err)
+	}
+
+	shift := map[string]int{"": 0, "b": 0, "k": 10, "m": 20, "g": 30}[match[2]]
+
+	return int64(value * float64(int64(1)<<shift)), nil
+}
+
+// formatResources describes the resources of a service on a single line, for diffs
+func formatResources(s Service) string {
+	var parts []string
+
+	if s.CPUs != 0 {
+		parts = append(parts, "cpus="+strconv.FormatFloat(s.CPUs, 'f', -1, 64))
+	}
+
+	if s.MemLimit != "" {
+		parts = append(parts, "mem_limit="+s.MemLimit)
+	}
+
+	if s.Deploy != nil && s.Deploy.Resources.Reservations != nil {
+		reservations := s.Deploy.Resources.Reservations
+		parts = append(parts, fmt.Sprintf("reservations=%s/%s", strconv.FormatFloat(reservations.CPUs, 'f', -1, 64), reservations.Memory))
+	}
+
+	if s.ShmSize != "" {
+		parts = append(parts, "shm_size="+s.ShmSize)
+	}
+
+	ulimits := make([]string, 0, len(s.Ulimits))
+	for name, ulimit := range s.Ulimits {
+		ulimits = append(ulimits, fmt.Sprintf("%s=%d:%d", name, ulimit.Soft, ulimit.Hard))
+	}
+
+	sort.Strings(ulimits)
+
+	return strings.Join(append(parts, ulimits...), ", ")
+}
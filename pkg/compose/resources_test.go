@@ -0,0 +1,222 @@
+package compose_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node/v2/config"
+	"github.com/rss3-network/node/v2/schema/worker/decentralized"
+	"github.com/rss3-network/protocol-go/schema/network"
+)
+
+func TestWithResources(t *testing.T) {
+	t.Parallel()
+
+	workers := []*config.Module{
+		{ID: "ethereum-core", Network: network.Ethereum, Worker: decentralized.Core},
+		{ID: "arbitrum-core", Network: network.Arbitrum, Worker: decentralized.Core},
+	}
+
+	testcases := []struct {
+		name      string
+		resources compose.ResourceConfig
+		expected  map[string]compose.Service
+	}{
+		{
+			name:      "defaults",
+			resources: compose.DefaultResources,
+			expected: map[string]compose.Service{
+				compose.ServiceName("alloydb"): {
+					CPUs: 4, MemLimit: "8g", ShmSize: "1g",
+					Ulimits: map[string]compose.Ulimit{"nofile": {Soft: 65536, Hard: 65536}},
+					Deploy: &compose.Deploy{Resources: compose.Resources{
+						Limits:       &compose.ResourceLimits{CPUs: 4, Memory: "8g"},
+						Reservations: &compose.ResourceLimits{CPUs: 1, Memory: "2g"},
+					}},
+				},
+				compose.ServiceName("monitor"): {
+					CPUs: 0.5, MemLimit: "512m",
+					Deploy: &compose.Deploy{Resources: compose.Resources{Limits: &compose.ResourceLimits{CPUs: 0.5, Memory: "512m"}}},
+				},
+				// The busiest networks get more resources than the other workers
+				compose.WorkerServiceName("ethereum-core"): {
+					CPUs: 2, MemLimit: "2g",
+					Deploy: &compose.Deploy{Resources: compose.Resources{
+						Limits:       &compose.ResourceLimits{CPUs: 2, Memory: "2g"},
+						Reservations: &compose.ResourceLimits{Memory: "128m"},
+					}},
+				},
+				compose.WorkerServiceName("arbitrum-core"): {
+					CPUs: 1, MemLimit: "1g",
+					Deploy: &compose.Deploy{Resources: compose.Resources{
+						Limits:       &compose.ResourceLimits{CPUs: 1, Memory: "1g"},
+						Reservations: &compose.ResourceLimits{Memory: "128m"},
+					}},
+				},
+			},
+		},
+		{
+			name: "overrides",
+			resources: compose.DefaultResources.Merge(compose.ResourceConfig{
+				Database: compose.ResourceSpec{Memory: "16g", Ulimits: map[string]compose.Ulimit{"nproc": {Soft: 4096, Hard: 4096}}},
+				Monitor:  compose.ResourceSpec{Reservations: compose.ResourceLimits{Memory: "64m"}},
+				Networks: map[string]compose.ResourceSpec{"arbitrum": {CPUs: 3}},
+			}),
+			expected: map[string]compose.Service{
+				compose.ServiceName("alloydb"): {
+					CPUs: 4, MemLimit: "16g", ShmSize: "1g",
+					Ulimits: map[string]compose.Ulimit{"nofile": {Soft: 65536, Hard: 65536}, "nproc": {Soft: 4096, Hard: 4096}},
+					Deploy: &compose.Deploy{Resources: compose.Resources{
+						Limits:       &compose.ResourceLimits{CPUs: 4, Memory: "16g"},
+						Reservations: &compose.ResourceLimits{CPUs: 1, Memory: "2g"},
+					}},
+				},
+				compose.ServiceName("monitor"): {
+					CPUs: 0.5, MemLimit: "512m",
+					Deploy: &compose.Deploy{Resources: compose.Resources{
+						Limits:       &compose.ResourceLimits{CPUs: 0.5, Memory: "512m"},
+						Reservations: &compose.ResourceLimits{Memory: "64m"},
+					}},
+				},
+				compose.WorkerServiceName("ethereum-core"): {
+					CPUs: 2, MemLimit: "2g",
+					Deploy: &compose.Deploy{Resources: compose.Resources{
+						Limits:       &compose.ResourceLimits{CPUs: 2, Memory: "2g"},
+						Reservations: &compose.ResourceLimits{Memory: "128m"},
+					}},
+				},
+				compose.WorkerServiceName("arbitrum-core"): {
+					CPUs: 3, MemLimit: "1g",
+					Deploy: &compose.Deploy{Resources: compose.Resources{
+						Limits:       &compose.ResourceLimits{CPUs: 3, Memory: "1g"},
+						Reservations: &compose.ResourceLimits{Memory: "128m"},
+					}},
+				},
+			},
+		},
+		{
+			// Docker rejects more CPUs than the host has
+			name:      "capped to the cpus of the host",
+			resources: compose.DefaultResources.CapCPUs(1.5),
+			expected: map[string]compose.Service{
+				compose.ServiceName("alloydb"): {
+					CPUs: 1.5, MemLimit: "8g", ShmSize: "1g",
+					Ulimits: map[string]compose.Ulimit{"nofile": {Soft: 65536, Hard: 65536}},
+					Deploy: &compose.Deploy{Resources: compose.Resources{
+						Limits:       &compose.ResourceLimits{CPUs: 1.5, Memory: "8g"},
+						Reservations: &compose.ResourceLimits{CPUs: 1, Memory: "2g"},
+					}},
+				},
+				compose.ServiceName("monitor"): {
+					CPUs: 0.5, MemLimit: "512m",
+					Deploy: &compose.Deploy{Resources: compose.Resources{Limits: &compose.ResourceLimits{CPUs: 0.5, Memory: "512m"}}},
+				},
+				compose.WorkerServiceName("ethereum-core"): {
+					CPUs: 1.5, MemLimit: "2g",
+					Deploy: &compose.Deploy{Resources: compose.Resources{
+						Limits:       &compose.ResourceLimits{CPUs: 1.5, Memory: "2g"},
+						Reservations: &compose.ResourceLimits{Memory: "128m"},
+					}},
+				},
+				compose.WorkerServiceName("arbitrum-core"): {
+					CPUs: 1, MemLimit: "1g",
+					Deploy: &compose.Deploy{Resources: compose.Resources{
+						Limits:       &compose.ResourceLimits{CPUs: 1, Memory: "1g"},
+						Reservations: &compose.ResourceLimits{Memory: "128m"},
+					}},
+				},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := compose.NewCompose(compose.WithWorkers(workers), compose.WithResources(testcase.resources, workers))
+
+			for name, expected := range testcase.expected {
+				service := c.Services[name]
+				actual := compose.Service{
+					CPUs:     service.CPUs,
+					MemLimit: service.MemLimit,
+					ShmSize:  service.ShmSize,
+					Ulimits:  service.Ulimits,
+					Deploy:   service.Deploy,
+				}
+
+				if !reflect.DeepEqual(actual, expected) {
+					t.Errorf("expected the resources of %s to be %+v, got %+v", name, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestResourceConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name      string
+		resources compose.ResourceConfig
+		err       string
+	}{
+		{name: "defaults", resources: compose.DefaultResources},
+		{
+			name:      "invalid memory",
+			resources: compose.ResourceConfig{Core: compose.ResourceSpec{Memory: "2 gigabytes"}},
+			err:       `invalid resources of core, invalid memory size "2 gigabytes", must be a number of bytes with an optional unit b, k, m or g`,
+		},
+		{
+			name:      "invalid memory of a network",
+			resources: compose.ResourceConfig{Networks: map[string]compose.ResourceSpec{"ethereum": {ShmSize: "-1g"}}},
+			err:       `invalid resources of networks.ethereum, invalid memory size "-1g", must be a number of bytes with an optional unit b, k, m or g`,
+		},
+		{
+			name:      "negative cpus",
+			resources: compose.ResourceConfig{Worker: compose.ResourceSpec{Reservations: compose.ResourceLimits{CPUs: -1}}},
+			err:       "invalid resources of worker, cpus must be positive",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := testcase.resources.Validate()
+			if testcase.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != testcase.err {
+				t.Errorf("expected the error %q, got %v", testcase.err, err)
+			}
+		})
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		size     string
+		expected int64
+	}{
+		{size: "512", expected: 512},
+		{size: "64k", expected: 64 << 10},
+		{size: "512m", expected: 512 << 20},
+		{size: "2G", expected: 2 << 30},
+		{size: "1.5gb", expected: 3 << 29},
+	}
+
+	for _, testcase := range testcases {
+		if actual, err := compose.ParseMemory(testcase.size); err != nil || actual != testcase.expected {
+			t.Errorf("expected %s to be %d bytes, got %d, %v", testcase.size, testcase.expected, actual, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package compose
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/secrets"
+)
+
+// SecretFile is a top-level secret of the compose file, read from a file on the host
+type SecretFile struct {
+	File string `yaml:"file"`
+}
+
+// fileEnvironment are the secret environment variables the images can also read from a file,
+// whose path is given by the variable with the _FILE suffix
+var fileEnvironment = map[string]bool{
+	"POSTGRES_PASSWORD": true,
+}
+
+// ExtractSecrets replaces the credentials in the environment and commands of the services by ${VARIABLE} references,
+// which docker compose resolves from the .env file next to the compose file.
+// It returns the variables to write to the .env file.
+func ExtractSecrets(c *Compose) map[string]string {
+	variables := make(map[string]string)
+
+	for _, name := range sortedServiceNames(c) {
+		service := c.Services[name]
+
+		for _, key := range sortedSecretKeys(service.Environment) {
+			value := service.Environment[key]
+
+			// Services sharing a variable name with different values get their own variable
+			variable := key
+			if existing, ok := variables[variable]; ok && existing != value {
+				variable = strings.ToUpper(fmt.Sprintf("%s_%s", strings.TrimPrefix(name, dockerComposeContainerNamePrefix+"_"), key))
+			}
+
+			variables[variable] = value
+			reference := fmt.Sprintf("${%s}", variable)
+
+			service.Environment[key] = reference
+			service.Command = strings.ReplaceAll(service.Command, value, reference)
+		}
+
+		c.Services[name] = service
+	}
+
+	return variables
+}
+
+// MountSecrets moves the credentials the images can read from files into compose secrets stored in dir,
+// mounted under /run/secrets. It returns the content of every secret file by its path,
+// the other credentials are left to ExtractSecrets.
+func MountSecrets(c *Compose, dir string) map[string]string {
+	files := make(map[string]string)
+
+	for _, name := range sortedServiceNames(c) {
+		service := c.Services[name]
+
+		for _, key := range sortedSecretKeys(service.Environment) {
+			if !fileEnvironment[key] {
+				continue
+			}
+
+			secretName := strings.ToLower(fmt.Sprintf("%s_%s", name, key))
+			file := filepath.Join(dir, secretName)
+
+			if c.Secrets == nil {
+				c.Secrets = make(map[string]SecretFile)
+			}
+
+			c.Secrets[secretName] = SecretFile{File: "./" + filepath.ToSlash(file)}
+			files[file] = service.Environment[key]
+
+			delete(service.Environment, key)
+			service.Environment[key+"_FILE"] = "/run/secrets/" + secretName
+			service.Secrets = append(service.Secrets, secretName)
+		}
+
+		c.Services[name] = service
+	}
+
+	return files
+}
+
+func sortedServiceNames(c *Compose) []string {
+	names := make([]string, 0, len(c.Services))
+	for name := range c.Services {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// sortedSecretKeys returns the keys of the environment holding credentials, skipping the paths of mounted secrets
+func sortedSecretKeys(environment map[string]string) []string {
+	var keys []string
+
+	for key, value := range environment {
+		if secrets.IsSensitiveKey(key) && !strings.HasSuffix(key, "_FILE") && value != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -2,12 +2,11 @@ package compose
 
 import "os"
 
-// NodeVersion read env from NODE_VERSION
-// if NODE_VERSION is not set, return "beta"
-func NodeVersion() (string, error) {
+// NodeVersion returns the version set by NODE_VERSION, or the node version of the manifest
+func (m *Manifest) NodeVersion() string {
 	if env := os.Getenv("NODE_VERSION"); env != "" {
-		return env, nil
+		return env
 	}
 
-	return "beta", nil
+	return m.Image("node").Tag
 }
@@ -0,0 +1,810 @@
+package configfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const defaultIndent = 2
+
+// File is a YAML config file which can be patched while keeping its comments and formatting.
+// Values are replaced or inserted in place in the original text, the rest of the file is left untouched.
+type File struct {
+	path    string
+	mode    os.FileMode
+	content []byte
+	root    yaml.Node
+	// crlf is set for files with Windows line endings, they are patched with LF line endings and converted back
+	crlf bool
+}
+
+// Load reads and parses a config file
+func Load(path string) (*File, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load config file, %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("load config file, %w", err)
+	}
+
+	f, err := Parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	f.path = path
+	f.mode = info.Mode().Perm()
+
+	return f, nil
+}
+
+// Parse parses the content of a config file which is not backed by a file on disk
+func Parse(content []byte) (*File, error) {
+	f := &File{
+		mode:    0644,
+		content: content,
+	}
+
+	if lines := bytes.Count(content, []byte("\n")); lines > 0 && bytes.Count(content, []byte("\r\n")) == lines {
+		f.content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+		f.crlf = true
+	}
+
+	if err := f.parse(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Path returns the path the file was loaded from
+func (f *File) Path() string {
+	return f.path
+}
+
+// Bytes returns the current content of the file
+func (f *File) Bytes() []byte {
+	if f.crlf {
+		return bytes.ReplaceAll(f.content, []byte("\n"), []byte("\r\n"))
+	}
+
+	return f.content
+}
+
+// Root returns the document node of the file
+func (f *File) Root() *yaml.Node {
+	return &f.root
+}
+
+// Get returns the node at the path, or nil if any element of the path is not found.
+// Elements of the path index sequences when they are numbers.
+func (f *File) Get(path []string) *yaml.Node {
+	if len(f.root.Content) == 0 {
+		return nil
+	}
+
+	return Lookup(f.root.Content[0], path...)
+}
+
+// Set sets the value at the path, creating the missing mappings along the way.
+// A *yaml.Node value is used as is, any other value is encoded first.
+func (f *File) Set(path []string, value any) error {
+	if len(path) == 0 {
+		return errors.New("set config value, empty path")
+	}
+
+	valueNode, ok := value.(*yaml.Node)
+	if !ok {
+		valueNode = new(yaml.Node)
+		if err := valueNode.Encode(value); err != nil {
+			return fmt.Errorf("set config value %s, %w", strings.Join(path, "."), err)
+		}
+	}
+
+	if content, ok := f.patch(path, valueNode); ok && f.apply(content) {
+		return nil
+	}
+
+	// A document without any value, e.g. only comments, gets the value appended
+	if len(f.root.Content) == 0 {
+		rendered, err := encode(nest(path, valueNode), defaultIndent)
+		if err != nil {
+			return fmt.Errorf("set config value %s, %w", strings.Join(path, "."), err)
+		}
+
+		f.content = insertLines(f.content, bytes.Count(f.content, []byte("\n"))+1, rendered)
+
+		return f.parse()
+	}
+
+	// The value can't be patched in place, fall back to encoding the whole document
+	if err := setNode(&f.root, path, valueNode); err != nil {
+		return errors.Join(fmt.Errorf("set config value %s, %w", strings.Join(path, "."), err), f.parse())
+	}
+
+	if err := f.reencode(); err != nil {
+		return fmt.Errorf("set config value %s, %w", strings.Join(path, "."), err)
+	}
+
+	return nil
+}
+
+// Unset removes the entry at the path, or the sequence item if the last element of the path is an index.
+// It returns false if the path is not found.
+func (f *File) Unset(path []string) (bool, error) {
+	if len(path) == 0 {
+		return false, errors.New("unset config value, empty path")
+	}
+
+	parent := f.Get(path[:len(path)-1])
+
+	keyNode, node := entry(parent, path[len(path)-1])
+	if node == nil {
+		return false, nil
+	}
+
+	if content, ok := f.remove(parent, keyNode, node); ok && f.apply(content) {
+		return true, nil
+	}
+
+	// The entry can't be removed in place, fall back to encoding the whole document
+	for i, child := range parent.Content {
+		if child != node {
+			continue
+		}
+
+		if parent.Kind == yaml.MappingNode {
+			parent.Content = append(parent.Content[:i-1], parent.Content[i+1:]...)
+		} else {
+			parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
+		}
+
+		break
+	}
+
+	if err := f.reencode(); err != nil {
+		return false, fmt.Errorf("unset config value %s, %w", strings.Join(path, "."), err)
+	}
+
+	return true, nil
+}
+
+// reencode replaces the content by the encoded document after a change which can't be done in place.
+// It refuses to drop comments, leaving the file unchanged instead.
+func (f *File) reencode() error {
+	content, err := encode(&f.root, f.indent())
+	if err != nil {
+		return errors.Join(err, f.parse())
+	}
+
+	kept := comments(content)
+
+	for comment, count := range comments(f.content) {
+		if kept[comment] < count {
+			return errors.Join(fmt.Errorf("the change can't be made without dropping the comment %q, edit the file by hand", comment), f.parse())
+		}
+	}
+
+	f.content = content
+
+	return f.parse()
+}
+
+// ParsePath splits a dotted path such as component.decentralized[2].parameters into its elements,
+// indexes of sequences become elements of their own
+func ParsePath(path string) ([]string, error) {
+	var elements []string
+
+	for _, part := range strings.Split(path, ".") {
+		key, rest, hasIndex := strings.Cut(part, "[")
+
+		if key == "" {
+			return nil, fmt.Errorf("invalid path %s, empty key", path)
+		}
+
+		elements = append(elements, key)
+
+		for hasIndex {
+			var index string
+
+			index, rest, hasIndex = strings.Cut(rest, "]")
+			if !hasIndex {
+				return nil, fmt.Errorf("invalid path %s, missing ]", path)
+			}
+
+			if _, err := strconv.Atoi(index); err != nil {
+				return nil, fmt.Errorf("invalid path %s, index %s is not a number", path, index)
+			}
+
+			elements = append(elements, index)
+
+			if rest == "" {
+				break
+			}
+
+			if !strings.HasPrefix(rest, "[") {
+				return nil, fmt.Errorf("invalid path %s, unexpected %s", path, rest)
+			}
+
+			rest = rest[1:]
+		}
+	}
+
+	return elements, nil
+}
+
+// Save writes the file atomically: the content is written to a temporary file which is synced
+// and renamed over the original, the previous version is kept with a .bak suffix.
+// Nothing is written if the content is unchanged.
+func (f *File) Save() error {
+	if f.path == "" {
+		return errors.New("save config file, the file has no path")
+	}
+
+	if previous, err := os.ReadFile(f.path); err == nil {
+		if bytes.Equal(previous, f.Bytes()) {
+			return nil
+		}
+
+		if err := os.WriteFile(f.path+".bak", previous, f.mode); err != nil {
+			return fmt.Errorf("save config file, write backup, %w", err)
+		}
+	}
+
+	dir := filepath.Dir(f.path)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("save config file, create temporary file, %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(f.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save config file, write temporary file, %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save config file, sync temporary file, %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save config file, close temporary file, %w", err)
+	}
+
+	if err := os.Chmod(tmp.Name(), f.mode); err != nil {
+		return fmt.Errorf("save config file, %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("save config file, rename temporary file, %w", err)
+	}
+
+	// Persist the rename, directories can't be synced on every platform so errors are ignored
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
+
+// Lookup follows the path from the node, returning nil if any element of the path is not found
+func Lookup(node *yaml.Node, path ...string) *yaml.Node {
+	for _, key := range path {
+		if _, node = entry(node, key); node == nil {
+			return nil
+		}
+	}
+
+	return node
+}
+
+func (f *File) parse() error {
+	var root yaml.Node
+
+	if err := yaml.Unmarshal(f.content, &root); err != nil {
+		return fmt.Errorf("parse config file, %w", err)
+	}
+
+	f.root = root
+
+	return nil
+}
+
+// apply replaces the content by the patched text, unless the patch broke the document
+func (f *File) apply(content []byte) bool {
+	var root yaml.Node
+
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return false
+	}
+
+	f.content = content
+	f.root = root
+
+	return true
+}
+
+// comments counts the comments of the content, the text after a # starting a line or following a space
+func comments(content []byte) map[string]int {
+	found := make(map[string]int)
+
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		index := strings.Index(trimmed, " #")
+		if strings.HasPrefix(trimmed, "#") {
+			index = -1
+		} else if index < 0 {
+			continue
+		}
+
+		found[strings.TrimSpace(trimmed[index+1:])]++
+	}
+
+	return found
+}
+
+// entry returns the key and the value of a mapping entry, or the item of a sequence when the key is an index
+func entry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil {
+		return nil, nil
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i], node.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		if index, err := strconv.Atoi(key); err == nil && index >= 0 && index < len(node.Content) {
+			return nil, node.Content[index]
+		}
+	}
+
+	return nil, nil
+}
+
+// patch edits the original text for the common cases: replacing a scalar, filling an empty value
+// and adding entries to a block mapping. It returns false when the edit can't be done in place.
+func (f *File) patch(path []string, valueNode *yaml.Node) ([]byte, bool) {
+	if len(f.root.Content) == 0 {
+		return nil, false
+	}
+
+	parent := f.root.Content[0]
+
+	for i, key := range path {
+		keyNode, node := entry(parent, key)
+
+		if node == nil {
+			if parent.Kind != yaml.MappingNode || parent.Style&yaml.FlowStyle != 0 || len(parent.Content) == 0 {
+				return nil, false
+			}
+
+			return f.insertEntry(parent, path[i], nest(path[i+1:], valueNode))
+		}
+
+		if i == len(path)-1 {
+			return f.replaceValue(keyNode, node, valueNode)
+		}
+
+		// Fill an empty intermediate value with the rest of the path
+		if isEmpty(node) {
+			return f.replaceValue(keyNode, node, nest(path[i+1:], valueNode))
+		}
+
+		parent = node
+	}
+
+	return nil, false
+}
+
+// replaceValue replaces a scalar value, either on the line of its key or as a block below it
+func (f *File) replaceValue(keyNode, node, valueNode *yaml.Node) ([]byte, bool) {
+	if node.Kind != yaml.ScalarNode || node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return nil, false
+	}
+
+	start, end, ok := f.span(keyNode, node)
+	if !ok {
+		return nil, false
+	}
+
+	if valueNode.Kind == yaml.ScalarNode {
+		rendered, err := encode(valueNode, defaultIndent)
+		if err != nil {
+			return nil, false
+		}
+
+		inline := string(bytes.TrimSuffix(rendered, []byte("\n")))
+		if strings.Contains(inline, "\n") {
+			return nil, false
+		}
+
+		if start == end {
+			inline = " " + inline
+		}
+
+		return splice(f.content, start, end, []byte(inline)), true
+	}
+
+	// A block value goes below its key, which must be known to indent the block
+	if keyNode == nil || keyNode.Line != node.Line && !isEmpty(node) {
+		return nil, false
+	}
+
+	// Remove the old value and the spaces before it
+	for start > 0 && f.content[start-1] == ' ' {
+		start--
+	}
+
+	content := splice(f.content, start, end, nil)
+
+	rendered, err := encode(valueNode, f.indent())
+	if err != nil {
+		return nil, false
+	}
+
+	return insertLines(content, keyNode.Line, indentLines(rendered, keyNode.Column-1+f.indent())), true
+}
+
+// insertEntry adds a new entry after the last entry of a block mapping
+func (f *File) insertEntry(mapping *yaml.Node, key string, valueNode *yaml.Node) ([]byte, bool) {
+	entryNode := &yaml.Node{
+		Kind: yaml.MappingNode,
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: key},
+			valueNode,
+		},
+	}
+
+	rendered, err := encode(entryNode, f.indent())
+	if err != nil {
+		return nil, false
+	}
+
+	column := mapping.Content[0].Column - 1
+
+	return insertLines(f.content, f.endLine(mapping, column), indentLines(rendered, column)), true
+}
+
+// remove deletes the lines of a block mapping entry or a block sequence item
+func (f *File) remove(parent, keyNode, node *yaml.Node) ([]byte, bool) {
+	if parent.Style&yaml.FlowStyle != 0 {
+		return nil, false
+	}
+
+	first := node
+	if keyNode != nil {
+		first = keyNode
+	}
+
+	lineStart, ok := f.offset(first.Line, 1)
+	if !ok {
+		return nil, false
+	}
+
+	start, ok := f.offset(first.Line, first.Column)
+	if !ok {
+		return nil, false
+	}
+
+	prefix := string(f.content[lineStart:start])
+
+	// A sequence item starts with a dash, which must be the only thing before the item on its line
+	if keyNode == nil {
+		trimmed := strings.TrimRight(prefix, " ")
+		if !strings.HasSuffix(trimmed, "-") || strings.TrimSpace(trimmed) != "-" {
+			return nil, false
+		}
+
+		prefix = trimmed[:len(trimmed)-1]
+	}
+
+	if strings.TrimLeft(prefix, " ") != "" {
+		return nil, false
+	}
+
+	end, ok := f.offset(f.endLine(node, len(prefix))+1, 1)
+	if !ok {
+		end = len(f.content)
+	}
+
+	return splice(f.content, lineStart, end, nil), true
+}
+
+// span returns the byte range of a scalar in the original text.
+// For an empty value the range is empty and placed right after the colon following its key.
+func (f *File) span(keyNode, node *yaml.Node) (int, int, bool) {
+	if isEmpty(node) {
+		if keyNode == nil || keyNode.Style != 0 {
+			return 0, 0, false
+		}
+
+		start, ok := f.offset(keyNode.Line, keyNode.Column)
+		if !ok {
+			return 0, 0, false
+		}
+
+		colon := start + len(keyNode.Value)
+		for colon < len(f.content) && f.content[colon] == ' ' {
+			colon++
+		}
+
+		if colon >= len(f.content) || f.content[colon] != ':' {
+			return 0, 0, false
+		}
+
+		return colon + 1, colon + 1, true
+	}
+
+	start, ok := f.offset(node.Line, node.Column)
+	if !ok {
+		return 0, 0, false
+	}
+
+	switch {
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(f.content) && f.content[i] != '\n'; i++ {
+			switch f.content[i] {
+			case '\\':
+				i++
+			case '"':
+				return start, i + 1, true
+			}
+		}
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(f.content) && f.content[i] != '\n'; i++ {
+			if f.content[i] != '\'' {
+				continue
+			}
+
+			if i+1 < len(f.content) && f.content[i+1] == '\'' {
+				i++
+				continue
+			}
+
+			return start, i + 1, true
+		}
+	default:
+		end := start + len(node.Value)
+		if end <= len(f.content) && string(f.content[start:end]) == node.Value {
+			return start, end, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// offset converts a line and a column, both starting at 1, into a byte offset
+func (f *File) offset(line, column int) (int, bool) {
+	offset := 0
+
+	for l := 1; l < line; l++ {
+		next := bytes.IndexByte(f.content[offset:], '\n')
+		if next < 0 {
+			return 0, false
+		}
+
+		offset += next + 1
+	}
+
+	for c := 1; c < column; c++ {
+		if offset >= len(f.content) {
+			return 0, false
+		}
+
+		_, size := utf8.DecodeRune(f.content[offset:])
+		offset += size
+	}
+
+	return offset, true
+}
+
+// endLine returns the last line of a node, including the continuation lines indented deeper than the column
+func (f *File) endLine(node *yaml.Node, column int) int {
+	end := lastLine(node)
+
+	lines := strings.Split(string(f.content), "\n")
+
+	for l := end + 1; l <= len(lines); l++ {
+		trimmed := strings.TrimSpace(lines[l-1])
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if len(lines[l-1])-len(strings.TrimLeft(lines[l-1], " ")) <= column {
+			break
+		}
+
+		end = l
+	}
+
+	return end
+}
+
+// indent returns the indentation of nested mappings used by the file
+func (f *File) indent() int {
+	indent := 0
+
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		for i := 0; i+1 < len(node.Content) && node.Kind == yaml.MappingNode; i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+
+			if valueNode.Kind == yaml.MappingNode && len(valueNode.Content) > 0 && valueNode.Style&yaml.FlowStyle == 0 {
+				if step := valueNode.Content[0].Column - keyNode.Column; step > 0 && (indent == 0 || step < indent) {
+					indent = step
+				}
+			}
+		}
+
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+
+	walk(&f.root)
+
+	if indent == 0 {
+		return defaultIndent
+	}
+
+	return indent
+}
+
+func lastLine(node *yaml.Node) int {
+	line := node.Line
+
+	if node.Kind == yaml.ScalarNode && node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		line += strings.Count(strings.TrimSuffix(node.Value, "\n"), "\n") + 1
+	}
+
+	for _, child := range node.Content {
+		line = max(line, lastLine(child))
+	}
+
+	return line
+}
+
+// isEmpty reports whether a node is an empty value, e.g. "key:" without anything after it
+func isEmpty(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null" && node.Value == ""
+}
+
+// nest wraps the value into mappings, one for each element of the path
+func nest(path []string, valueNode *yaml.Node) *yaml.Node {
+	for i := len(path) - 1; i >= 0; i-- {
+		valueNode = &yaml.Node{
+			Kind: yaml.MappingNode,
+			Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Value: path[i]},
+				valueNode,
+			},
+		}
+	}
+
+	return valueNode
+}
+
+// setNode sets the value at the path in the node tree, creating the missing mappings along the way
+func setNode(root *yaml.Node, path []string, valueNode *yaml.Node) error {
+	if len(root.Content) == 0 {
+		root.Kind = yaml.DocumentNode
+		root.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
+	}
+
+	parent := root.Content[0]
+
+	for i, key := range path {
+		_, node := entry(parent, key)
+
+		if node == nil || i == len(path)-1 {
+			if parent.Kind == yaml.SequenceNode && node != nil {
+				*node = *valueNode
+				return nil
+			}
+
+			if parent.Kind != yaml.MappingNode {
+				return fmt.Errorf("%s is not a mapping", strings.Join(path[:i], "."))
+			}
+
+			if node != nil {
+				*node = *valueNode
+				return nil
+			}
+
+			parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, nest(path[i+1:], valueNode))
+
+			return nil
+		}
+
+		if isEmpty(node) {
+			*node = yaml.Node{Kind: yaml.MappingNode}
+		}
+
+		parent = node
+	}
+
+	return nil
+}
+
+func encode(node *yaml.Node, indent int) ([]byte, error) {
+	var b bytes.Buffer
+
+	e := yaml.NewEncoder(&b)
+	e.SetIndent(indent)
+
+	if err := e.Encode(node); err != nil {
+		return nil, err
+	}
+
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func indentLines(content []byte, indent int) []byte {
+	prefix := strings.Repeat(" ", indent)
+	lines := strings.SplitAfter(string(content), "\n")
+
+	var b strings.Builder
+
+	for _, line := range lines {
+		if line != "" && line != "\n" {
+			b.WriteString(prefix)
+		}
+
+		b.WriteString(line)
+	}
+
+	return []byte(b.String())
+}
+
+// insertLines inserts the lines after the given line, starting at 1
+func insertLines(content []byte, line int, lines []byte) []byte {
+	offset := 0
+
+	for l := 0; l < line && offset < len(content); l++ {
+		next := bytes.IndexByte(content[offset:], '\n')
+		if next < 0 {
+			offset = len(content)
+			break
+		}
+
+		offset += next + 1
+	}
+
+	if offset == len(content) && len(content) > 0 && content[len(content)-1] != '\n' {
+		lines = append([]byte("\n"), lines...)
+	}
+
+	return splice(content, offset, offset, lines)
+}
+
+func splice(content []byte, start, end int, replacement []byte) []byte {
+	result := make([]byte, 0, len(content)-(end-start)+len(replacement))
+	result = append(result, content[:start]...)
+	result = append(result, replacement...)
+
+	return append(result, content[end:]...)
+}
@@ -0,0 +1,330 @@
+package configfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/configfile"
+)
+
+func TestParsePath(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		path      string
+		expected  []string
+		expectErr bool
+	}{
+		{path: "database", expected: []string{"database"}},
+		{path: "database.uri", expected: []string{"database", "uri"}},
+		{path: "component.decentralized[2].parameters", expected: []string{"component", "decentralized", "2", "parameters"}},
+		{path: "matrix[1][0]", expected: []string{"matrix", "1", "0"}},
+		{path: "", expectErr: true},
+		{path: "database..uri", expectErr: true},
+		{path: "[0]", expectErr: true},
+		{path: "list[0", expectErr: true},
+		{path: "list[a]", expectErr: true},
+		{path: "list[0]x", expectErr: true},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.path, func(t *testing.T) {
+			t.Parallel()
+
+			path, err := configfile.ParsePath(testcase.path)
+			if testcase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", path)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(path, testcase.expected) {
+				t.Errorf("expected %q, got %q", testcase.expected, path)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name      string
+		content   string
+		path      []string
+		value     any
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "replace a scalar",
+			content:  "# top\na: 1 # one\nb: 2\n",
+			path:     []string{"a"},
+			value:    3,
+			expected: "# top\na: 3 # one\nb: 2\n",
+		},
+		{
+			name:     "replace a quoted scalar",
+			content:  "a: \"x\" # quoted\n",
+			path:     []string{"a"},
+			value:    "y",
+			expected: "a: \"y\" # quoted\n",
+		},
+		{
+			name:     "add a nested entry after the last one",
+			content:  "a:\n  # b\n  b: 1\n\n# c\nc: 2\n",
+			path:     []string{"a", "d"},
+			value:    "x",
+			expected: "a:\n  # b\n  b: 1\n  d: x\n\n# c\nc: 2\n",
+		},
+		{
+			name:     "create the missing mappings",
+			content:  "a: 1\n",
+			path:     []string{"b", "c", "d"},
+			value:    true,
+			expected: "a: 1\nb:\n  c:\n    d: true\n",
+		},
+		{
+			name:     "fill an empty value",
+			content:  "a:\nb: 1\n",
+			path:     []string{"a", "c"},
+			value:    "v",
+			expected: "a:\n  c: v\nb: 1\n",
+		},
+		{
+			name:     "keep the indentation of the file",
+			content:  "a:\n    b: 1\n",
+			path:     []string{"c", "d"},
+			value:    2,
+			expected: "a:\n    b: 1\nc:\n    d: 2\n",
+		},
+		{
+			name:     "sequence item",
+			content:  "l:\n  - x: 1\n  - x: 2 # two\n",
+			path:     []string{"l", "1", "x"},
+			value:    3,
+			expected: "l:\n  - x: 1\n  - x: 3 # two\n",
+		},
+		{
+			name:     "comment only document",
+			content:  "# hi\n",
+			path:     []string{"a", "b"},
+			value:    1,
+			expected: "# hi\na:\n  b: 1\n",
+		},
+		{
+			name:     "empty document",
+			content:  "",
+			path:     []string{"a"},
+			value:    1,
+			expected: "a: 1\n",
+		},
+		{
+			name:     "crlf line endings",
+			content:  "# top\r\na: 1\r\nb:\r\n  c: 2\r\n",
+			path:     []string{"b", "d"},
+			value:    3,
+			expected: "# top\r\na: 1\r\nb:\r\n  c: 2\r\n  d: 3\r\n",
+		},
+		{
+			name:     "crlf line endings re-encoded",
+			content:  "# top\r\na: {b: 1} # flow\r\n",
+			path:     []string{"a", "c"},
+			value:    2,
+			expected: "# top\r\na: {b: 1, c: 2} # flow\r\n",
+		},
+		{
+			name:     "flow mapping re-encoded with its comments",
+			content:  "# top\na: {b: 1} # flow\nc: 2\n",
+			path:     []string{"a", "x"},
+			value:    "v",
+			expected: "# top\na: {b: 1, x: v} # flow\nc: 2\n",
+		},
+		{
+			name:     "multiline flow mapping re-encoded",
+			content:  "a: {\n  # inner\n  b: 1\n}\n",
+			path:     []string{"b", "d"},
+			value:    3,
+			expected: "a: {\n  # inner\n  b: 1}\nb:\n  d: 3\n",
+		},
+		{
+			name:      "not a mapping",
+			content:   "a: [1, 2]\n",
+			path:      []string{"a", "x"},
+			value:     "v",
+			expected:  "a: [1, 2]\n",
+			expectErr: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := configfile.Parse([]byte(testcase.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = f.Set(testcase.path, testcase.value)
+			if testcase.expectErr != (err != nil) {
+				t.Fatalf("expected an error %v, got %v", testcase.expectErr, err)
+			}
+
+			if string(f.Bytes()) != testcase.expected {
+				t.Errorf("expected %q, got %q", testcase.expected, f.Bytes())
+			}
+		})
+	}
+}
+
+func TestUnset(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name      string
+		content   string
+		path      []string
+		expected  string
+		found     bool
+		expectErr bool
+	}{
+		{
+			name:     "mapping entry",
+			content:  "# top\na: 1 # one\nb:\n  c: 2\n  d: 3\ne: 4\n",
+			path:     []string{"b", "c"},
+			expected: "# top\na: 1 # one\nb:\n  d: 3\ne: 4\n",
+			found:    true,
+		},
+		{
+			name:     "nested block",
+			content:  "a: 1\nb:\n  c:\n    d: 2\n  # e\n  e: 3\nf: 4\n",
+			path:     []string{"b", "c"},
+			expected: "a: 1\nb:\n  # e\n  e: 3\nf: 4\n",
+			found:    true,
+		},
+		{
+			name:     "sequence item",
+			content:  "l:\n  - x: 1\n    y: 2\n  - x: 3\n",
+			path:     []string{"l", "0"},
+			expected: "l:\n  - x: 3\n",
+			found:    true,
+		},
+		{
+			name:     "flow sequence item",
+			content:  "l: [1, 2] # list\n",
+			path:     []string{"l", "0"},
+			expected: "l: [2] # list\n",
+			found:    true,
+		},
+		{
+			name:      "refuse to drop a comment",
+			content:   "l: [1, # one\n  2, # two\n  3]\n",
+			path:      []string{"l", "1"},
+			expected:  "l: [1, # one\n  2, # two\n  3]\n",
+			expectErr: true,
+		},
+		{
+			name:     "crlf line endings",
+			content:  "a: 1\r\nb: 2\r\n",
+			path:     []string{"a"},
+			expected: "b: 2\r\n",
+			found:    true,
+		},
+		{
+			name:     "missing",
+			content:  "a: 1\n",
+			path:     []string{"b", "c"},
+			expected: "a: 1\n",
+		},
+		{
+			name:     "comment only document",
+			content:  "# hi\n",
+			path:     []string{"a"},
+			expected: "# hi\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := configfile.Parse([]byte(testcase.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			found, err := f.Unset(testcase.path)
+			if testcase.expectErr != (err != nil) {
+				t.Fatalf("expected an error %v, got %v", testcase.expectErr, err)
+			}
+
+			if found != testcase.found {
+				t.Errorf("expected found %v, got %v", testcase.found, found)
+			}
+
+			if string(f.Bytes()) != testcase.expected {
+				t.Errorf("expected %q, got %q", testcase.expected, f.Bytes())
+			}
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	original := "# node\r\nendpoints:\r\n  ethereum: http://localhost:8545\r\n"
+
+	if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := configfile.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Set([]string{"endpoints", "ethereum"}, "https://rpc.example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "# node\r\nendpoints:\r\n  ethereum: https://rpc.example.com\r\n"; string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, content)
+	}
+
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(backup) != original {
+		t.Errorf("expected the backup %q, got %q", original, backup)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %o", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,254 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Compose drives the Docker Compose CLI for a single compose file
+type Compose struct {
+	command []string
+	file    string
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+// ServiceStatus is a container as reported by docker compose ps --format json
+type ServiceStatus struct {
+	Name       string      `json:"Name"`
+	Service    string      `json:"Service"`
+	Image      string      `json:"Image"`
+	State      string      `json:"State"`
+	Health     string      `json:"Health"`
+	Status     string      `json:"Status"`
+	ExitCode   int         `json:"ExitCode"`
+	Publishers []Publisher `json:"Publishers"`
+}
+
+type Publisher struct {
+	URL           string `json:"URL"`
+	TargetPort    int    `json:"TargetPort"`
+	PublishedPort int    `json:"PublishedPort"`
+	Protocol      string `json:"Protocol"`
+}
+
+// IsRunning reports whether the container is running and not unhealthy
+func (s ServiceStatus) IsRunning() bool {
+	return s.State == "running" && s.Health != "unhealthy"
+}
+
+type Option func(*Compose)
+
+// WithCommand overrides the detected compose CLI, e.g. "docker compose" or the path of a fake executable
+func WithCommand(command string) Option {
+	return func(c *Compose) {
+		c.command = strings.Fields(command)
+	}
+}
+
+// WithOutput sets where the output of the compose CLI is streamed to
+func WithOutput(stdout, stderr io.Writer) Option {
+	return func(c *Compose) {
+		c.stdout = stdout
+		c.stderr = stderr
+	}
+}
+
+// NewCompose returns a Compose for the given compose file,
+// detecting the compose CLI flavor unless a command is set explicitly
+func NewCompose(ctx context.Context, file string, options ...Option) (*Compose, error) {
+	c := &Compose{
+		file:   file,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	if len(c.command) == 0 {
+		command, err := DetectCommand(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		c.command = command
+	}
+
+	return c, nil
+}
+
+// DetectCommand finds the installed compose CLI, preferring the docker compose plugin
+// over the legacy standalone docker-compose binary
+func DetectCommand(ctx context.Context) ([]string, error) {
+	if _, err := exec.LookPath("docker"); err == nil {
+		if exec.CommandContext(ctx, "docker", "compose", "version").Run() == nil {
+			return []string{"docker", "compose"}, nil
+		}
+	}
+
+	if _, err := exec.LookPath("docker-compose"); err == nil {
+		return []string{"docker-compose"}, nil
+	}
+
+	return nil, errors.New("docker compose is not installed, please install Docker Compose: https://docs.docker.com/compose/install/")
+}
+
+// Up creates and starts the services in the background, removing the services no longer in the compose file
+func (c *Compose) Up(ctx context.Context) error {
+	return c.run(ctx, "up", "-d", "--remove-orphans")
+}
+
+// Down stops and removes the services
+func (c *Compose) Down(ctx context.Context) error {
+	return c.run(ctx, "down")
+}
+
+// Restart restarts the given services, or all of them if none is given
+func (c *Compose) Restart(ctx context.Context, services ...string) error {
+	return c.run(ctx, append([]string{"restart"}, services...)...)
+}
+
+// Logs streams the logs of the given services, or all of them if none is given
+func (c *Compose) Logs(ctx context.Context, follow bool, tail string, services ...string) error {
+	args := []string{"logs"}
+
+	if follow {
+		args = append(args, "--follow")
+	}
+
+	if tail != "" {
+		args = append(args, "--tail", tail)
+	}
+
+	return c.run(ctx, append(args, services...)...)
+}
+
+// Status returns the status of every container of the compose project, including stopped ones
+func (c *Compose) Status(ctx context.Context) ([]ServiceStatus, error) {
+	var stdout bytes.Buffer
+
+	cmd := c.newCmd(ctx, "ps", "--all", "--format", "json")
+	cmd.Stdout = &stdout
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s ps, %w", strings.Join(c.command, " "), err)
+	}
+
+	return ParseStatus(stdout.Bytes())
+}
+
+// WaitHealthy polls the status until every given service is running and, if it has a healthcheck, healthy.
+// It returns an error listing the services not ready yet once the context is done.
+func (c *Compose) WaitHealthy(ctx context.Context, services []string, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		statuses, err := c.Status(ctx)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+
+		pending := pendingServices(statuses, services)
+		if len(pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("services not healthy: %s", strings.Join(pending, ", "))
+		case <-ticker.C:
+		}
+	}
+}
+
+// pendingServices returns the services which are not running or not healthy yet
+func pendingServices(statuses []ServiceStatus, services []string) []string {
+	ready := make(map[string]bool, len(statuses))
+
+	for _, status := range statuses {
+		ready[status.Service] = status.IsRunning() && (status.Health == "" || status.Health == "healthy")
+	}
+
+	var pending []string
+
+	for _, service := range services {
+		if !ready[service] {
+			pending = append(pending, service)
+		}
+	}
+
+	return pending
+}
+
+// ParseStatus parses the output of docker compose ps --format json,
+// older releases print a JSON array while newer ones print one JSON object per line
+func ParseStatus(data []byte) ([]ServiceStatus, error) {
+	data = bytes.TrimSpace(data)
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var statuses []ServiceStatus
+
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &statuses); err != nil {
+			return nil, fmt.Errorf("parse compose status, %w", err)
+		}
+
+		return statuses, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	for {
+		var status ServiceStatus
+
+		err := decoder.Decode(&status)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("parse compose status, %w", err)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (c *Compose) run(ctx context.Context, args ...string) error {
+	cmd := c.newCmd(ctx, args...)
+	cmd.Stdout = c.stdout
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s, %w", strings.Join(c.command, " "), args[0], err)
+	}
+
+	return nil
+}
+
+func (c *Compose) newCmd(ctx context.Context, args ...string) *exec.Cmd {
+	arguments := make([]string, 0, len(c.command)+len(args)+1)
+	arguments = append(arguments, c.command[1:]...)
+	arguments = append(arguments, "-f", c.file)
+	arguments = append(arguments, args...)
+
+	cmd := exec.CommandContext(ctx, c.command[0], arguments...)
+	cmd.Stderr = c.stderr
+
+	return cmd
+}
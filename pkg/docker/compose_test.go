@@ -0,0 +1,171 @@
+package docker_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/docker"
+)
+
+//nolint:paralleltest // t.Setenv changes PATH for the whole process
+func TestDetectCommand(t *testing.T) {
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory with the legacy docker-compose binary only
+	legacy := t.TempDir()
+	if err := os.Symlink(filepath.Join(testdata, "docker-compose"), filepath.Join(legacy, "docker-compose")); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name      string
+		path      string
+		noPlugin  bool
+		expected  []string
+		expectErr bool
+	}{
+		{name: "plugin preferred over docker-compose", path: testdata, expected: []string{"docker", "compose"}},
+		{name: "docker without the plugin", path: testdata, noPlugin: true, expected: []string{"docker-compose"}},
+		{name: "docker-compose only", path: legacy, expected: []string{"docker-compose"}},
+		{name: "none", path: t.TempDir(), expectErr: true},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Setenv("PATH", testcase.path)
+			t.Setenv("FAKE_DOCKER_NO_COMPOSE", map[bool]string{true: "1"}[testcase.noPlugin])
+
+			command, err := docker.DetectCommand(context.Background())
+			if testcase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", command)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(command, testcase.expected) {
+				t.Errorf("expected %v, got %v", testcase.expected, command)
+			}
+		})
+	}
+}
+
+//nolint:paralleltest // t.Setenv sets where the fake docker CLI records its arguments
+func TestCompose(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "docker.log")
+
+	t.Setenv("FAKE_DOCKER_LOG", log)
+	t.Setenv("FAKE_DOCKER_HEALTH", "healthy")
+
+	file := filepath.Join("testdata", "docker-compose.yaml")
+	ctx := context.Background()
+
+	c, err := docker.NewCompose(ctx, file, docker.WithCommand(filepath.Join("testdata", "docker")+" compose"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Restart(ctx, "rss3_node_core"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.WaitHealthy(ctx, []string{"rss3_node_core", "rss3_node_redis"}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Down(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"compose -f " + file + " up -d --remove-orphans",
+		"compose -f " + file + " restart rss3_node_core",
+		"compose -f " + file + " ps --all --format json",
+		"compose -f " + file + " down",
+	}
+
+	if commands := strings.Split(strings.TrimSpace(string(content)), "\n"); !reflect.DeepEqual(commands, expected) {
+		t.Errorf("expected commands:\n%s\ngot:\n%s", strings.Join(expected, "\n"), content)
+	}
+}
+
+//nolint:paralleltest // t.Setenv sets the health reported by the fake docker CLI
+func TestComposeWaitHealthyTimeout(t *testing.T) {
+	t.Setenv("FAKE_DOCKER_HEALTH", "unhealthy")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	c, err := docker.NewCompose(ctx, filepath.Join("testdata", "docker-compose.yaml"), docker.WithCommand(filepath.Join("testdata", "docker")+" compose"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.WaitHealthy(ctx, []string{"rss3_node_core"}, 10*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "rss3_node_core") {
+		t.Errorf("expected rss3_node_core not to be healthy, got %v", err)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	t.Parallel()
+
+	expected := []docker.ServiceStatus{
+		{Name: "rss3_node_core", Service: "rss3_node_core", State: "running", Status: "Up 2 minutes"},
+		{Name: "rss3_node_alloydb", Service: "rss3_node_alloydb", State: "running", Health: "healthy", Status: "Up 2 minutes (healthy)"},
+	}
+
+	testcases := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "json lines",
+			data: `{"Name":"rss3_node_core","Service":"rss3_node_core","State":"running","Status":"Up 2 minutes"}
+{"Name":"rss3_node_alloydb","Service":"rss3_node_alloydb","State":"running","Health":"healthy","Status":"Up 2 minutes (healthy)"}
+`,
+		},
+		{
+			name: "json array",
+			data: `[{"Name":"rss3_node_core","Service":"rss3_node_core","State":"running","Status":"Up 2 minutes"},
+{"Name":"rss3_node_alloydb","Service":"rss3_node_alloydb","State":"running","Health":"healthy","Status":"Up 2 minutes (healthy)"}]`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			statuses, err := docker.ParseStatus([]byte(testcase.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(statuses, expected) {
+				t.Errorf("expected %+v, got %+v", expected, statuses)
+			}
+		})
+	}
+}
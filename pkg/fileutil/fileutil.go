@@ -0,0 +1,38 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes the content to the file with the permissions, creating its directory readable by the owner only
+func WriteFile(file string, content []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("create directory of %s, %w", file, err)
+	}
+
+	if err := os.WriteFile(file, content, perm); err != nil {
+		return fmt.Errorf("write %s, %w", file, err)
+	}
+
+	// WriteFile keeps the permissions of an existing file
+	if err := os.Chmod(file, perm); err != nil {
+		return fmt.Errorf("set permissions of %s, %w", file, err)
+	}
+
+	return nil
+}
+
+// MkdirAll creates the directory with the permissions, which also replace the ones of an existing directory
+func MkdirAll(dir string, perm os.FileMode) error {
+	if err := os.MkdirAll(dir, perm); err != nil {
+		return fmt.Errorf("create directory %s, %w", dir, err)
+	}
+
+	if err := os.Chmod(dir, perm); err != nil {
+		return fmt.Errorf("set permissions of %s, %w", dir, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Prober checks a single dependency of the deployment once
+type Prober interface {
+	// Target describes what is probed, e.g. a URL or an address
+	Target() string
+	// Probe checks the target, returning a short description of its state, e.g. the version of a server
+	Probe(ctx context.Context) (string, error)
+}
+
+// Result is the outcome of probing a target until it was healthy or the deadline passed
+type Result struct {
+	Target   string        `json:"target"`
+	Healthy  bool          `json:"healthy"`
+	Detail   string        `json:"detail,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Attempts int           `json:"attempts"`
+	Duration time.Duration `json:"duration"`
+
+	err error
+}
+
+// Err returns the error of the last attempt, or nil if the target is healthy
+func (r *Result) Err() error {
+	return r.err
+}
+
+// permanentError is an error retrying can't fix, such as a server too old
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks the error of a probe as permanent, Check doesn't retry it
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Backoff is the delay between two attempts, growing by Multiplier from Initial up to Max
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// Delay returns the delay after the given attempt, starting at 1
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := float64(b.Initial)
+	for i := 1; i < attempt; i++ {
+		delay *= max(b.Multiplier, 1)
+	}
+
+	if b.Max > 0 {
+		delay = min(delay, float64(b.Max))
+	}
+
+	return time.Duration(delay)
+}
+
+type settings struct {
+	attempts       int
+	attemptTimeout time.Duration
+	deadline       time.Duration
+	backoff        Backoff
+}
+
+type Option func(*settings)
+
+// WithAttempts limits the number of attempts, 0 retries until the deadline
+func WithAttempts(attempts int) Option {
+	return func(s *settings) {
+		s.attempts = attempts
+	}
+}
+
+// WithAttemptTimeout limits the duration of every attempt
+func WithAttemptTimeout(timeout time.Duration) Option {
+	return func(s *settings) {
+		s.attemptTimeout = timeout
+	}
+}
+
+// WithDeadline limits the duration of all the attempts together, on top of the deadline of the context
+func WithDeadline(deadline time.Duration) Option {
+	return func(s *settings) {
+		s.deadline = deadline
+	}
+}
+
+// WithBackoff sets the delay between two attempts
+func WithBackoff(backoff Backoff) Option {
+	return func(s *settings) {
+		s.backoff = backoff
+	}
+}
+
+// DefaultBackoff doubles the delay from 1s up to 10s
+var DefaultBackoff = Backoff{Initial: time.Second, Max: 10 * time.Second, Multiplier: 2}
+
+// Check probes the target until it is healthy, the attempts are exhausted or the deadline passes.
+// By default it tries 3 times, 5s each, within 30s.
+func Check(ctx context.Context, prober Prober, options ...Option) *Result {
+	s := &settings{
+		attempts:       3,
+		attemptTimeout: 5 * time.Second,
+		deadline:       30 * time.Second,
+		backoff:        DefaultBackoff,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	if s.deadline > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, s.deadline)
+		defer cancel()
+	}
+
+	result := &Result{Target: prober.Target()}
+	start := time.Now()
+
+	for result.Attempts = 1; ; result.Attempts++ {
+		result.Detail, result.err = probe(ctx, prober, s.attemptTimeout)
+		if result.err == nil {
+			result.Healthy = true
+
+			break
+		}
+
+		var permanent *permanentError
+		if errors.As(result.err, &permanent) || s.attempts > 0 && result.Attempts >= s.attempts {
+			break
+		}
+
+		timer := time.NewTimer(s.backoff.Delay(result.Attempts))
+
+		select {
+		case <-ctx.Done():
+			// Keep the error of the last attempt, it tells why the target is unhealthy better than the deadline
+			timer.Stop()
+		case <-timer.C:
+			continue
+		}
+
+		break
+	}
+
+	result.Duration = time.Since(start)
+
+	if result.err != nil {
+		result.Error = result.err.Error()
+	}
+
+	return result
+}
+
+// probe runs a single attempt with its own timeout, released before the next attempt
+func probe(ctx context.Context, prober Prober, timeout time.Duration) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	detail, err := prober.Probe(ctx)
+	if err != nil && errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+		return detail, fmt.Errorf("timed out after %s", timeout)
+	}
+
+	return detail, err
+}
@@ -0,0 +1,183 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/health"
+)
+
+// fakeProber fails with the errors in turn, then succeeds; a nil error in the list succeeds too
+type fakeProber struct {
+	errors   []error
+	calls    int
+	blocking bool
+}
+
+func (p *fakeProber) Target() string {
+	return "fake"
+}
+
+func (p *fakeProber) Probe(ctx context.Context) (string, error) {
+	p.calls++
+
+	if p.blocking {
+		<-ctx.Done()
+
+		return "", ctx.Err()
+	}
+
+	if p.calls <= len(p.errors) && p.errors[p.calls-1] != nil {
+		return "", p.errors[p.calls-1]
+	}
+
+	return "ok", nil
+}
+
+var errUnavailable = errors.New("unavailable")
+
+// fastBackoff keeps the tests quick
+var fastBackoff = health.WithBackoff(health.Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond, Multiplier: 2})
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		prober   *fakeProber
+		options  []health.Option
+		healthy  bool
+		attempts int
+		err      string
+	}{
+		{
+			name:     "healthy at once",
+			prober:   &fakeProber{},
+			healthy:  true,
+			attempts: 1,
+		},
+		{
+			name:     "healthy after retries",
+			prober:   &fakeProber{errors: []error{errUnavailable, errUnavailable}},
+			healthy:  true,
+			attempts: 3,
+		},
+		{
+			name:     "attempts exhausted",
+			prober:   &fakeProber{errors: []error{errUnavailable, errUnavailable, errUnavailable}},
+			attempts: 3,
+			err:      "unavailable",
+		},
+		{
+			name:     "more attempts",
+			prober:   &fakeProber{errors: []error{errUnavailable, errUnavailable, errUnavailable}},
+			options:  []health.Option{health.WithAttempts(5)},
+			healthy:  true,
+			attempts: 4,
+		},
+		{
+			name:     "permanent error is not retried",
+			prober:   &fakeProber{errors: []error{health.Permanent(errUnavailable)}},
+			attempts: 1,
+			err:      "unavailable",
+		},
+		{
+			name:     "attempt timeout",
+			prober:   &fakeProber{blocking: true},
+			options:  []health.Option{health.WithAttempts(2), health.WithAttemptTimeout(10 * time.Millisecond)},
+			attempts: 2,
+			err:      "timed out after 10ms",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := health.Check(context.Background(), testcase.prober, append([]health.Option{fastBackoff}, testcase.options...)...)
+
+			if result.Healthy != testcase.healthy {
+				t.Errorf("expected healthy %v, got %v", testcase.healthy, result.Healthy)
+			}
+
+			if result.Attempts != testcase.attempts || testcase.prober.calls != testcase.attempts {
+				t.Errorf("expected %d attempts, got %d and %d probes", testcase.attempts, result.Attempts, testcase.prober.calls)
+			}
+
+			if testcase.err == "" {
+				if result.Err() != nil || result.Error != "" || result.Detail != "ok" {
+					t.Errorf("expected no error and the detail ok, got %v and %q", result.Err(), result.Detail)
+				}
+
+				return
+			}
+
+			if result.Err() == nil || !strings.Contains(result.Error, testcase.err) {
+				t.Errorf("expected the error %q, got %q", testcase.err, result.Error)
+			}
+		})
+	}
+}
+
+func TestCheckDeadline(t *testing.T) {
+	t.Parallel()
+
+	prober := &fakeProber{errors: make([]error, 1000)}
+	for i := range prober.errors {
+		prober.errors[i] = errUnavailable
+	}
+
+	result := health.Check(context.Background(), prober, health.WithAttempts(0), health.WithDeadline(50*time.Millisecond),
+		health.WithBackoff(health.Backoff{Initial: 10 * time.Millisecond}))
+
+	if result.Healthy || result.Attempts < 2 {
+		t.Errorf("expected several unhealthy attempts, got %d healthy %v", result.Attempts, result.Healthy)
+	}
+
+	if result.Duration > time.Second {
+		t.Errorf("expected the deadline to stop the attempts, took %s", result.Duration)
+	}
+
+	// The error of the last attempt is kept rather than the deadline
+	if !errors.Is(result.Err(), errUnavailable) {
+		t.Errorf("expected %v, got %v", errUnavailable, result.Err())
+	}
+}
+
+func TestCheckCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result := health.Check(ctx, &fakeProber{})
+	if result.Healthy || !errors.Is(result.Err(), context.Canceled) {
+		t.Errorf("expected %v, got healthy %v and %v", context.Canceled, result.Healthy, result.Err())
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		backoff  health.Backoff
+		attempt  int
+		expected time.Duration
+	}{
+		{backoff: health.DefaultBackoff, attempt: 1, expected: time.Second},
+		{backoff: health.DefaultBackoff, attempt: 2, expected: 2 * time.Second},
+		{backoff: health.DefaultBackoff, attempt: 4, expected: 8 * time.Second},
+		{backoff: health.DefaultBackoff, attempt: 5, expected: 10 * time.Second},
+		{backoff: health.Backoff{Initial: time.Second}, attempt: 3, expected: time.Second},
+		{backoff: health.Backoff{Initial: time.Second, Multiplier: 3}, attempt: 3, expected: 9 * time.Second},
+	}
+
+	for _, testcase := range testcases {
+		if actual := testcase.backoff.Delay(testcase.attempt); actual != testcase.expected {
+			t.Errorf("expected the delay %s of %+v after attempt %d, got %s", testcase.expected, testcase.backoff, testcase.attempt, actual)
+		}
+	}
+}
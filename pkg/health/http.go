@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// StatusError is returned when the server answered with an unexpected status, telling an unhealthy server from an unreachable one
+type StatusError struct {
+	Status     string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected status " + e.Status
+}
+
+// HTTPProber sends a GET request to a URL, healthy if it answers with a 2xx status
+type HTTPProber struct {
+	URL    string
+	Header http.Header
+	Client *http.Client
+	// Validate checks the body of a 2xx response if set, returning the detail of the probe
+	Validate func(body []byte) (string, error)
+}
+
+// NewHTTPProber returns an HTTPProber of the URL, verifying TLS connections with the given configuration if not nil
+func NewHTTPProber(url string, tlsConfig *tls.Config) *HTTPProber {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &HTTPProber{
+		URL:    url,
+		Client: &http.Client{Transport: transport},
+	}
+}
+
+func (p *HTTPProber) Target() string {
+	return p.URL
+}
+
+func (p *HTTPProber) Probe(ctx context.Context) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	for key, values := range p.Header {
+		request.Header[key] = values
+	}
+
+	response, err := p.Client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	// Read the body in any case, so the connection can be reused by the next attempt
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return response.Status, &StatusError{Status: response.Status, StatusCode: response.StatusCode}
+	}
+
+	if err != nil {
+		return response.Status, fmt.Errorf("read response of %s, %w", p.URL, err)
+	}
+
+	if p.Validate != nil {
+		return p.Validate(body)
+	}
+
+	return response.Status, nil
+}
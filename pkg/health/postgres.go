@@ -0,0 +1,44 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/postgres"
+)
+
+// PostgresProber logs into the server of a connection URI, healthy if it runs at least MinimumVersion
+type PostgresProber struct {
+	URI            string
+	MinimumVersion int
+
+	// Server is the server found by the last successful attempt
+	Server *postgres.Server
+}
+
+// Target returns the URI without its password
+func (p *PostgresProber) Target() string {
+	uri, err := url.Parse(p.URI)
+	if err != nil {
+		return "invalid database uri"
+	}
+
+	return uri.Redacted()
+}
+
+func (p *PostgresProber) Probe(ctx context.Context) (string, error) {
+	server, err := postgres.Check(ctx, p.URI)
+	if err != nil {
+		return "", err
+	}
+
+	p.Server = server
+	detail := "PostgreSQL " + server.Version
+
+	if server.MajorVersion < p.MinimumVersion {
+		return detail, Permanent(fmt.Errorf("runs PostgreSQL %s, version %d or later is required", server.Version, p.MinimumVersion))
+	}
+
+	return detail, nil
+}
@@ -0,0 +1,391 @@
+package health_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/health"
+)
+
+func TestHTTPProber(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" && r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		switch r.URL.Path {
+		case "/healthz":
+			_, _ = w.Write([]byte("ok"))
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		case "/moved":
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	testcases := []struct {
+		name   string
+		path   string
+		header http.Header
+		status int
+	}{
+		{name: "ok", path: "/healthz", status: http.StatusOK},
+		{name: "created", path: "/created", status: http.StatusCreated},
+		{name: "header", path: "/healthz", header: http.Header{"Authorization": {"Bearer token"}}, status: http.StatusOK},
+		{name: "wrong header", path: "/healthz", header: http.Header{"Authorization": {"Bearer wrong"}}, status: http.StatusUnauthorized},
+		{name: "not modified", path: "/moved", status: http.StatusNotModified},
+		{name: "unavailable", path: "/unavailable", status: http.StatusServiceUnavailable},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			prober := health.NewHTTPProber(server.URL+testcase.path, nil)
+			prober.Header = testcase.header
+
+			detail, err := prober.Probe(context.Background())
+			if !strings.HasPrefix(detail, fmt.Sprint(testcase.status)) {
+				t.Errorf("expected the status %d, got %q", testcase.status, detail)
+			}
+
+			if testcase.status < http.StatusMultipleChoices {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			var statusError *health.StatusError
+			if !errors.As(err, &statusError) || statusError.StatusCode != testcase.status {
+				t.Errorf("expected a status error %d, got %v", testcase.status, err)
+			}
+		})
+	}
+}
+
+func TestHTTPProberValidate(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		_, _ = w.Write([]byte("indexing"))
+	}))
+	t.Cleanup(server.Close)
+
+	validate := func(body []byte) (string, error) {
+		if string(body) != "ready" {
+			return "", fmt.Errorf("status %s", body)
+		}
+
+		return "ready", nil
+	}
+
+	// The body of a 2xx response is checked, an error status is reported before
+	prober := health.NewHTTPProber(server.URL+"/status", nil)
+	prober.Validate = validate
+
+	if _, err := prober.Probe(context.Background()); err == nil || err.Error() != "status indexing" {
+		t.Errorf("expected the body to be rejected, got %v", err)
+	}
+
+	prober = health.NewHTTPProber(server.URL+"/unavailable", nil)
+	prober.Validate = validate
+
+	var statusError *health.StatusError
+	if _, err := prober.Probe(context.Background()); !errors.As(err, &statusError) {
+		t.Errorf("expected a status error, got %v", err)
+	}
+}
+
+func TestHTTPProberUnreachable(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	// An unreachable server isn't a status error
+	_, err := health.NewHTTPProber(server.URL, nil).Probe(context.Background())
+
+	var statusError *health.StatusError
+	if err == nil || errors.As(err, &statusError) {
+		t.Errorf("expected a connection error, got %v", err)
+	}
+}
+
+func TestHTTPProberTLS(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	// The failed handshakes are expected
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	// The certificate of the server is self-signed, for 127.0.0.1 and example.com
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name      string
+		options   health.TLSOptions
+		expectErr bool
+	}{
+		{name: "system roots", options: health.TLSOptions{}, expectErr: true},
+		{name: "ca file", options: health.TLSOptions{CAFile: caFile}},
+		{name: "ca file with server name", options: health.TLSOptions{CAFile: caFile, ServerName: "example.com"}},
+		{name: "ca file with wrong server name", options: health.TLSOptions{CAFile: caFile, ServerName: "rss3.io"}, expectErr: true},
+		{name: "insecure", options: health.TLSOptions{InsecureSkipVerify: true}},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			config, err := testcase.options.Config()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = health.NewHTTPProber(server.URL, config).Probe(context.Background())
+			if testcase.expectErr != (err != nil) {
+				t.Errorf("expected an error %v, got %v", testcase.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestTLSOptions(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	notPEM := filepath.Join(dir, "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, options := range []health.TLSOptions{
+		{CAFile: filepath.Join(dir, "missing.pem")},
+		{CAFile: notPEM},
+		{CertFile: filepath.Join(dir, "client.pem")},
+	} {
+		if _, err := options.Config(); err == nil {
+			t.Errorf("expected an error for %+v", options)
+		}
+	}
+}
+
+func TestTCPProber(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			_ = conn.Close()
+		}
+	}()
+
+	prober := &health.TCPProber{Address: listener.Addr().String()}
+
+	if _, err := prober.Probe(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = listener.Close()
+
+	if _, err := prober.Probe(context.Background()); err == nil {
+		t.Error("expected an error once the listener is closed")
+	}
+}
+
+// fakeRedis answers the AUTH and PING commands of the RESP protocol, requiring the password if not empty.
+// Like Redis 5 it doesn't know the HELLO command, the clients fall back to AUTH.
+func fakeRedis(t *testing.T, username, password, pong string) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveRedis(conn, username, password, pong)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func serveRedis(conn net.Conn, username, password, pong string) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	authenticated := password == ""
+
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+
+		// Commands are case insensitive, go-redis sends them in lower case
+		args[0] = strings.ToUpper(args[0])
+
+		var reply string
+
+		switch {
+		case args[0] == "AUTH" && (len(args) == 2 && username == "" && args[1] == password || len(args) == 3 && args[1] == username && args[2] == password):
+			authenticated, reply = true, "+OK"
+		case args[0] == "AUTH":
+			reply = "-WRONGPASS invalid username-password pair"
+		case !authenticated:
+			reply = "-NOAUTH Authentication required."
+		case args[0] == "PING":
+			reply = "+" + pong
+		default:
+			reply = "-ERR unknown command"
+		}
+
+		if _, err := conn.Write([]byte(reply + "\r\n")); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads an array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	count, err := readLength(reader, '*')
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+
+	for i := range args {
+		length, err := readLength(reader, '$')
+		if err != nil {
+			return nil, err
+		}
+
+		arg := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+
+		args[i] = string(arg[:length])
+	}
+
+	if count == 0 {
+		return nil, errors.New("empty command")
+	}
+
+	return args, nil
+}
+
+// readLength reads a line such as *2 or $4
+func readLength(reader *bufio.Reader, prefix byte) (int, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" || line[0] != prefix {
+		return 0, fmt.Errorf("unexpected line %q", line)
+	}
+
+	return strconv.Atoi(line[1:])
+}
+
+func TestRedisProber(t *testing.T) {
+	t.Parallel()
+
+	open := fakeRedis(t, "", "", "PONG")
+	protected := fakeRedis(t, "", "secret", "PONG")
+	acl := fakeRedis(t, "rss3", "secret", "PONG")
+	odd := fakeRedis(t, "", "", "PANG")
+
+	testcases := []struct {
+		name      string
+		prober    *health.RedisProber
+		expectErr bool
+		permanent bool
+	}{
+		{name: "no password", prober: &health.RedisProber{Address: open}},
+		{name: "password", prober: &health.RedisProber{Address: protected, Password: "secret"}},
+		{name: "username and password", prober: &health.RedisProber{Address: acl, Username: "rss3", Password: "secret"}},
+		{name: "missing password", prober: &health.RedisProber{Address: protected}, expectErr: true},
+		{name: "wrong password", prober: &health.RedisProber{Address: protected, Password: "wrong"}, expectErr: true, permanent: true},
+		{name: "wrong username", prober: &health.RedisProber{Address: acl, Username: "root", Password: "secret"}, expectErr: true, permanent: true},
+		{name: "unexpected reply", prober: &health.RedisProber{Address: odd}, expectErr: true},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := health.Check(context.Background(), testcase.prober, fastBackoff)
+			if testcase.expectErr == result.Healthy {
+				t.Fatalf("expected an error %v, got %v", testcase.expectErr, result.Err())
+			}
+
+			if !testcase.expectErr {
+				if result.Detail != "PONG" {
+					t.Errorf("expected the detail PONG, got %q", result.Detail)
+				}
+
+				return
+			}
+
+			// Authentication errors are permanent, the others are retried
+			if expected := map[bool]int{true: 1, false: 3}[testcase.permanent]; result.Attempts != expected {
+				t.Errorf("expected %d attempts, got %d", expected, result.Attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisProber logs into a Redis server and sends a PING, healthy if it answers PONG
+type RedisProber struct {
+	Address  string
+	Username string
+	Password string
+	// TLS is the configuration of the connection, nil for plain text
+	TLS *tls.Config
+}
+
+func (p *RedisProber) Target() string {
+	return p.Address
+}
+
+func (p *RedisProber) Probe(ctx context.Context) (string, error) {
+	var config *tls.Config
+
+	if p.TLS != nil {
+		config = p.TLS.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(p.Address)
+		}
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:      p.Address,
+		Username:  p.Username,
+		Password:  p.Password,
+		TLSConfig: config,
+		PoolSize:  1,
+		// Check retries the probe, the client doesn't
+		MaxRetries:      -1,
+		DisableIdentity: true,
+	})
+	defer client.Close()
+
+	reply, err := client.Ping(ctx).Result()
+	if err != nil {
+		if isAuthenticationError(err) {
+			return "", Permanent(fmt.Errorf("authenticate, %w", err))
+		}
+
+		return "", err
+	}
+
+	if reply != "PONG" {
+		return "", fmt.Errorf("unexpected reply %q to PING", reply)
+	}
+
+	return "PONG", nil
+}
+
+// isAuthenticationError reports whether Redis refused the credentials, WRONGPASS since Redis 6
+func isAuthenticationError(err error) bool {
+	var redisErr redis.Error
+
+	if !errors.As(err, &redisErr) {
+		return false
+	}
+
+	message := redisErr.Error()
+
+	return strings.HasPrefix(message, "WRONGPASS") || strings.HasPrefix(message, "ERR invalid password")
+}
@@ -0,0 +1,26 @@
+package health
+
+import (
+	"context"
+	"net"
+)
+
+// TCPProber opens a TCP connection to an address such as localhost:6379
+type TCPProber struct {
+	Address string
+}
+
+func (p *TCPProber) Target() string {
+	return p.Address
+}
+
+func (p *TCPProber) Probe(ctx context.Context) (string, error) {
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", p.Address)
+	if err != nil {
+		return "", err
+	}
+
+	return "accepting connections", conn.Close()
+}
@@ -0,0 +1,302 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/kubernetes"
+	"github.com/rss3-network/node/v2/config"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const defaultChartVersion = "0.1.0"
+
+// Placeholders are plain scalars that survive YAML encoding untouched,
+// they are replaced by Helm template expressions once the manifests are encoded.
+const (
+	placeholderNodeImage        = "__helm_node_image__"
+	placeholderNodeVersion      = "__helm_node_version__"
+	placeholderAlloyDBPassword  = "__helm_alloydb_password__"
+	placeholderAlloyDBURIPass   = "__helm_alloydb_uri_password__"
+	placeholderAlloyDBStorage   = "__helm_alloydb_storage__"
+	placeholderAgentdataAddress = "__helm_agentdata_endpoint__"
+)
+
+type Chart struct {
+	Metadata  Metadata
+	Values    Values
+	Templates map[string]string
+}
+
+type Metadata struct {
+	APIVersion  string `yaml:"apiVersion"`
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Type        string `yaml:"type"`
+	Version     string `yaml:"version"`
+	AppVersion  string `yaml:"appVersion"`
+}
+
+type Values struct {
+	Node      NodeValues              `yaml:"node"`
+	Workers   map[string]WorkerValues `yaml:"workers"`
+	AlloyDB   AlloyDBValues           `yaml:"alloydb"`
+	Agentdata AgentdataValues         `yaml:"agentdata"`
+}
+
+type NodeValues struct {
+	Image   string `yaml:"image"`
+	Version string `yaml:"version"`
+}
+
+type WorkerValues struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type AlloyDBValues struct {
+	Password string `yaml:"password"`
+	Storage  string `yaml:"storage"`
+}
+
+type AgentdataValues struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type Option func(*Chart)
+
+// WithChartVersion sets the version of the chart itself.
+func WithChartVersion(version string) Option {
+	return func(c *Chart) {
+		if version != "" {
+			c.Metadata.Version = version
+		}
+	}
+}
+
+// WithNodeVersion sets the default node image tag.
+func WithNodeVersion(version string) Option {
+	return func(c *Chart) {
+		c.Metadata.AppVersion = version
+		c.Values.Node.Version = version
+	}
+}
+
+// WithWorkers adds a toggle for every worker, all enabled by default.
+func WithWorkers(workers []*config.Module) Option {
+	return func(c *Chart) {
+		for _, worker := range workers {
+			c.Values.Workers[worker.ID] = WorkerValues{Enabled: true}
+		}
+	}
+}
+
+// WithAlloyDBPassword sets the default AlloyDB password, the same password used in the connection URIs.
+func WithAlloyDBPassword(password string) Option {
+	return func(c *Chart) {
+		c.Values.AlloyDB.Password = password
+	}
+}
+
+// WithAgentdata sets whether the local agentdata service is enabled by default.
+func WithAgentdata(enabled bool) Option {
+	return func(c *Chart) {
+		c.Values.Agentdata.Enabled = enabled
+	}
+}
+
+// NewChart builds a Helm chart from the compose service model and the node config file.
+// Node version, workers, AlloyDB credentials and agentdata are exposed as values.
+func NewChart(c *compose.Compose, configFile []byte, options ...Option) (*Chart, error) {
+	chart := &Chart{
+		Metadata: Metadata{
+			APIVersion:  "v2",
+			Name:        "rss3-node",
+			Description: "RSS3 Node",
+			Type:        "application",
+			Version:     defaultChartVersion,
+		},
+		Values: Values{
+			Node:    NodeValues{Image: compose.NodeImage},
+			Workers: make(map[string]WorkerValues),
+			AlloyDB: AlloyDBValues{Storage: "100Gi"},
+		},
+		Templates: make(map[string]string),
+	}
+
+	for _, option := range options {
+		option(chart)
+	}
+
+	templated, agentdataEndpoint := chart.templateCompose(c)
+	configFile = chart.templateConnectionURI(configFile)
+
+	expressions := strings.NewReplacer(
+		placeholderNodeImage, "{{ .Values.node.image }}",
+		placeholderNodeVersion, "{{ .Values.node.version }}",
+		placeholderAlloyDBPassword, "{{ .Values.alloydb.password | quote }}",
+		placeholderAlloyDBURIPass, "{{ .Values.alloydb.password | urlquery }}",
+		placeholderAlloyDBStorage, "{{ .Values.alloydb.storage }}",
+		placeholderAgentdataAddress, strconv.Quote(agentdataEndpoint),
+	)
+
+	var b bytes.Buffer
+	if err := kubernetes.Encode(&b, []any{kubernetes.NewConfigSecret(templated, configFile)}); err != nil {
+		return nil, err
+	}
+
+	chart.Templates["config.yaml"] = expressions.Replace(b.String())
+
+	if credentials := kubernetes.NewCredentialsSecret(templated); len(credentials.StringData) > 0 {
+		b.Reset()
+
+		if err := kubernetes.Encode(&b, []any{credentials}); err != nil {
+			return nil, err
+		}
+
+		chart.Templates["credentials.yaml"] = expressions.Replace(b.String())
+	}
+
+	conditions := make(map[string]string)
+	for id := range chart.Values.Workers {
+		conditions[compose.WorkerServiceName(id)] = fmt.Sprintf("(index .Values.workers %q).enabled", id)
+	}
+
+	conditions[compose.ServiceName("agentdata")] = ".Values.agentdata.enabled"
+
+	for name := range templated.Services {
+		objects, err := kubernetes.NewServiceManifests(templated, name, kubernetes.WithStorageSize(placeholderAlloyDBStorage))
+		if err != nil {
+			return nil, err
+		}
+
+		b.Reset()
+
+		if err := kubernetes.Encode(&b, objects); err != nil {
+			return nil, err
+		}
+
+		content := expressions.Replace(agentdataEnvPattern.ReplaceAllString(b.String(), agentdataEnvTemplate))
+
+		if condition, ok := conditions[name]; ok {
+			content = fmt.Sprintf("{{- if %s }}\n%s{{- end }}\n", condition, content)
+		}
+
+		chart.Templates[kubernetes.ResourceName(name)+".yaml"] = content
+	}
+
+	return chart, nil
+}
+
+// agentdataEnvPattern matches the environment variable pointing the node services to the agentdata service,
+// agentdataEnvTemplate leaves it out when agentdata is disabled rather than rendering an empty endpoint
+var (
+	agentdataEnvPattern  = regexp.MustCompile(`(?m)^(( *)- name: NODE_COMPONENT_AI_ENDPOINT\n *value: ` + placeholderAgentdataAddress + `\n)`)
+	agentdataEnvTemplate = "${2}{{- if .Values.agentdata.enabled }}\n${1}${2}{{- end }}\n"
+)
+
+// Write writes the chart into a directory, creating it if needed.
+func (c *Chart) Write(dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0755); err != nil {
+		return fmt.Errorf("write chart, create directory, %w", err)
+	}
+
+	if err := writeYAML(filepath.Join(dir, "Chart.yaml"), c.Metadata); err != nil {
+		return fmt.Errorf("write chart, %w", err)
+	}
+
+	if err := writeYAML(filepath.Join(dir, "values.yaml"), c.Values); err != nil {
+		return fmt.Errorf("write chart, %w", err)
+	}
+
+	names := make([]string, 0, len(c.Templates))
+	for name := range c.Templates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, "templates", name), []byte(c.Templates[name]), 0600); err != nil {
+			return fmt.Errorf("write chart, write template %s, %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// templateCompose returns a copy of the compose service model with placeholders in place of the values,
+// and the in-cluster endpoint of the agentdata service.
+func (c *Chart) templateCompose(source *compose.Compose) (*compose.Compose, string) {
+	templated := &compose.Compose{
+		Services: make(map[string]compose.Service, len(source.Services)),
+		Volumes:  source.Volumes,
+	}
+
+	var agentdataEndpoint string
+
+	for name, service := range source.Services {
+		if strings.HasPrefix(service.Image, compose.NodeImage) {
+			service.Image = fmt.Sprintf("%s:%s", placeholderNodeImage, placeholderNodeVersion)
+		}
+
+		environment := make(map[string]string, len(service.Environment))
+
+		for key, value := range service.Environment {
+			switch {
+			case key == "POSTGRES_PASSWORD" && name == compose.ServiceName("alloydb"):
+				value = placeholderAlloyDBPassword
+			case key == "NODE_COMPONENT_AI_ENDPOINT":
+				agentdataEndpoint = strings.ReplaceAll(value, compose.ServiceName("agentdata"), kubernetes.ResourceName(compose.ServiceName("agentdata")))
+				value = placeholderAgentdataAddress
+			default:
+				value = string(c.templateConnectionURI([]byte(value)))
+			}
+
+			environment[key] = value
+		}
+
+		if service.Environment != nil {
+			service.Environment = environment
+		}
+
+		templated.Services[name] = service
+	}
+
+	return templated, agentdataEndpoint
+}
+
+// templateConnectionURI replaces the AlloyDB password in connection URIs, where it is percent-encoded.
+func (c *Chart) templateConnectionURI(content []byte) []byte {
+	if c.Values.AlloyDB.Password == "" {
+		return content
+	}
+
+	userinfo := url.UserPassword("", c.Values.AlloyDB.Password).String()
+
+	return bytes.ReplaceAll(content, []byte(userinfo+"@"), []byte(":"+placeholderAlloyDBURIPass+"@"))
+}
+
+func writeYAML(file string, value any) error {
+	var b bytes.Buffer
+
+	e := yaml.NewEncoder(&b)
+	e.SetIndent(2)
+
+	if err := e.Encode(value); err != nil {
+		return fmt.Errorf("encode %s, %w", filepath.Base(file), err)
+	}
+
+	if err := os.WriteFile(file, b.Bytes(), 0600); err != nil {
+		return fmt.Errorf("write %s, %w", filepath.Base(file), err)
+	}
+
+	return nil
+}
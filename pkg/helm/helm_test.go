@@ -0,0 +1,248 @@
+package helm_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"text/template"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/helm"
+	"github.com/rss3-network/node-automated-deployer/pkg/kubernetes"
+	"github.com/rss3-network/node/v2/config"
+	"github.com/rss3-network/node/v2/schema/worker/decentralized"
+	"github.com/rss3-network/protocol-go/schema/network"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+var workers = []*config.Module{
+	{ID: "ethereum-core", Network: network.Ethereum, Worker: decentralized.Core},
+}
+
+func TestNewChart(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name      string
+		agentdata bool
+	}{
+		{
+			name:      "default",
+			agentdata: true,
+		},
+		{
+			name:      "agentdata_disabled",
+			agentdata: false,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			chart, err := newChart("password", testcase.agentdata)
+			if err != nil {
+				t.Fatalf("new chart: %v", err)
+			}
+
+			dir := t.TempDir()
+			if err := chart.Write(dir); err != nil {
+				t.Fatalf("write chart: %v", err)
+			}
+
+			content := readChart(t, dir)
+			golden := filepath.Join("testdata", testcase.name+".golden")
+
+			if *update {
+				if err := os.WriteFile(golden, content, 0644); err != nil {
+					t.Fatalf("update golden file: %v", err)
+				}
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden file, run go test -update to create it: %v", err)
+			}
+
+			if !bytes.Equal(content, expected) {
+				t.Errorf("chart differs from %s, run go test -update if the change is expected:\n%s", golden, content)
+			}
+		})
+	}
+}
+
+func TestNewChartConnectionURI(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		password string
+		value    string
+	}{
+		{
+			name:     "default value",
+			password: "password",
+		},
+		{
+			name:     "set value",
+			password: "password",
+			value:    "changed",
+		},
+		{
+			// The characters with a meaning in a URI must be escaped in the userinfo
+			name:     "special characters in the default value",
+			password: "p@ss:w/rd%",
+		},
+		{
+			name:     "special characters in the replaced value",
+			password: "p@ss:w/rd%",
+			value:    "changed",
+		},
+		{
+			name:     "special characters in the set value",
+			password: "password",
+			value:    "p@ss:w/rd%",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			chart, err := newChart(testcase.password, true)
+			if err != nil {
+				t.Fatalf("new chart: %v", err)
+			}
+
+			// Render the config Secret the way Helm does, like helm install --set alloydb.password=<value>
+			tmpl, err := template.New("config.yaml").
+				Funcs(template.FuncMap{"quote": strconv.Quote}).
+				Parse(chart.Templates["config.yaml"])
+			if err != nil {
+				t.Fatalf("parse template: %v", err)
+			}
+
+			// Templates address the values by their keys in values.yaml
+			content, err := yaml.Marshal(chart.Values)
+			if err != nil {
+				t.Fatalf("encode values: %v", err)
+			}
+
+			var values map[string]map[string]any
+			if err := yaml.Unmarshal(content, &values); err != nil {
+				t.Fatalf("decode values: %v", err)
+			}
+
+			expected := testcase.password
+			if testcase.value != "" {
+				values["alloydb"]["password"] = testcase.value
+				expected = testcase.value
+			}
+
+			var b bytes.Buffer
+			if err := tmpl.Execute(&b, map[string]any{"Values": values}); err != nil {
+				t.Fatalf("render template: %v", err)
+			}
+
+			var secret kubernetes.Secret
+			if err := yaml.Unmarshal(b.Bytes(), &secret); err != nil {
+				t.Fatalf("decode secret: %v", err)
+			}
+
+			var configFile config.File
+			if err := yaml.Unmarshal([]byte(secret.StringData["config.yaml"]), &configFile); err != nil {
+				t.Fatalf("decode config file: %v", err)
+			}
+
+			uri, err := url.Parse(configFile.Database.URI)
+			if err != nil {
+				t.Fatalf("parse database uri %q: %v", configFile.Database.URI, err)
+			}
+
+			if password, _ := uri.User.Password(); password != expected {
+				t.Errorf("expected the password %q, got %q", expected, password)
+			}
+		})
+	}
+}
+
+// newChart builds the chart of a node with one worker and an AI component, like the chart command
+func newChart(password string, agentdata bool) (*helm.Chart, error) {
+	databaseURI := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword("postgres", password),
+		Host:   compose.ServiceName("alloydb") + ":5432",
+		Path:   "/postgres",
+	}
+
+	configFile := fmt.Sprintf("database:\n  uri: %s\nredis:\n  endpoint: rss3_node_redis:6379\n", databaseURI.String())
+
+	cfg := &config.File{
+		Database: &config.Database{URI: databaseURI.String()},
+		Component: &config.Component{
+			Decentralized: workers,
+			AI:            &config.Module{ID: "ai"},
+		},
+	}
+
+	composeFile := compose.NewCompose(
+		compose.WithWorkers(workers),
+		compose.SetDependsOnAlloyDB(),
+		compose.SetNodeVersion("v2.0.0"),
+		compose.SetNodeVolume("config"),
+		compose.SetRestartPolicy(),
+		compose.SetAlloyDBPassword(password),
+		compose.SetRedisPassword("password"),
+		compose.SetAIComponent(cfg, compose.AIComponentLocal),
+		compose.WithResources(compose.DefaultResources, workers),
+	)
+
+	return helm.NewChart(composeFile, []byte(configFile),
+		helm.WithNodeVersion("v2.0.0"),
+		helm.WithWorkers(workers),
+		helm.WithAlloyDBPassword(password),
+		helm.WithAgentdata(agentdata),
+	)
+}
+
+// readChart concatenates the files of a chart directory in a stable order
+func readChart(t *testing.T, dir string) []byte {
+	t.Helper()
+
+	var files []string
+
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			files = append(files, path)
+		}
+
+		return err
+	})
+	if err != nil {
+		t.Fatalf("read chart: %v", err)
+	}
+
+	sort.Strings(files)
+
+	var b bytes.Buffer
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read chart: %v", err)
+		}
+
+		name, _ := filepath.Rel(dir, file)
+		fmt.Fprintf(&b, "# Source: %s\n%s", filepath.ToSlash(name), content)
+	}
+
+	return b.Bytes()
+}
@@ -0,0 +1,520 @@
+package kubernetes
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/secrets"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// configSecretName is the name of the Secret holding the node config.yaml
+	configSecretName = "rss3-node-config"
+	// credentialsSecretName is the name of the Secret holding the credentials of the service environments
+	credentialsSecretName = "rss3-node-credentials"
+	// configMountPath is where the node services expect to find config.yaml
+	configMountPath = "/etc/rss3/node"
+
+	defaultStorageSize = "100Gi"
+)
+
+type settings struct {
+	namespace   string
+	storageSize string
+}
+
+type Option func(*settings)
+
+// WithNamespace sets the namespace of every generated object.
+func WithNamespace(namespace string) Option {
+	return func(s *settings) {
+		s.namespace = namespace
+	}
+}
+
+// WithStorageSize sets the size requested by the persistent volume claims.
+func WithStorageSize(size string) Option {
+	return func(s *settings) {
+		if size != "" {
+			s.storageSize = size
+		}
+	}
+}
+
+// NewManifests converts the compose service model into Kubernetes objects.
+// Services mounting a named volume become StatefulSets with a persistent volume claim,
+// all the others become Deployments. Every service with exposed or published ports gets a Service,
+// the node config file is shipped as a Secret mounted into the node services,
+// and the credentials of the environments are read from another Secret.
+func NewManifests(c *compose.Compose, configFile []byte, options ...Option) ([]any, error) {
+	names := make([]string, 0, len(c.Services))
+	for name := range c.Services {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	objects := []any{NewConfigSecret(c, configFile, options...)}
+
+	if credentials := NewCredentialsSecret(c, options...); len(credentials.StringData) > 0 {
+		objects = append(objects, credentials)
+	}
+
+	for _, name := range names {
+		serviceObjects, err := NewServiceManifests(c, name, options...)
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, serviceObjects...)
+	}
+
+	return objects, nil
+}
+
+// NewConfigSecret returns the Secret holding the node config file.
+func NewConfigSecret(c *compose.Compose, configFile []byte, options ...Option) Secret {
+	s := newSettings(options...)
+
+	return Secret{
+		TypeMeta: TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		Metadata: s.objectMeta(configSecretName, ""),
+		Type:     "Opaque",
+		StringData: map[string]string{
+			// Service names are used as host names, they must be valid DNS labels in Kubernetes
+			"config.yaml": newNameReplacer(c).Replace(string(configFile)),
+		},
+	}
+}
+
+// NewCredentialsSecret returns the Secret holding the credentials of the service environments,
+// the containers reference them by secretKeyRef rather than holding their values.
+func NewCredentialsSecret(c *compose.Compose, options ...Option) Secret {
+	s := newSettings(options...)
+	replacer := newNameReplacer(c)
+	credentials := make(map[string]string)
+
+	for name, service := range c.Services {
+		for key, value := range service.Environment {
+			if isCredential(key, value) {
+				credentials[credentialKey(ResourceName(name), key)] = replacer.Replace(value)
+			}
+		}
+	}
+
+	return Secret{
+		TypeMeta:   TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		Metadata:   s.objectMeta(credentialsSecretName, ""),
+		Type:       "Opaque",
+		StringData: credentials,
+	}
+}
+
+// NewServiceManifests returns the workload of a single compose service and its Service if it has any ports.
+func NewServiceManifests(c *compose.Compose, name string, options ...Option) ([]any, error) {
+	s := newSettings(options...)
+
+	service, ok := c.Services[name]
+	if !ok {
+		return nil, fmt.Errorf("service %s not found", name)
+	}
+
+	workload, ports, err := s.newWorkload(c, ResourceName(name), service, newNameReplacer(c))
+	if err != nil {
+		return nil, fmt.Errorf("convert service %s, %w", name, err)
+	}
+
+	objects := []any{workload}
+
+	if len(ports) > 0 {
+		objects = append(objects, Service{
+			TypeMeta: TypeMeta{APIVersion: "v1", Kind: "Service"},
+			Metadata: s.objectMeta(ResourceName(name), ResourceName(name)),
+			Spec: ServiceSpec{
+				Selector: selectorLabels(ResourceName(name)),
+				Ports:    ports,
+			},
+		})
+	}
+
+	return objects, nil
+}
+
+// Encode writes the objects as a multi-document YAML stream.
+func Encode(w io.Writer, objects []any) error {
+	e := yaml.NewEncoder(w)
+	e.SetIndent(2)
+
+	for _, object := range objects {
+		if err := e.Encode(object); err != nil {
+			return fmt.Errorf("encode kubernetes object, %w", err)
+		}
+	}
+
+	return e.Close()
+}
+
+func newSettings(options ...Option) *settings {
+	s := &settings{
+		storageSize: defaultStorageSize,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+func (s *settings) newWorkload(c *compose.Compose, name string, service compose.Service, replacer *strings.Replacer) (any, []ServicePort, error) {
+	container := Container{
+		Name:           name,
+		Image:          service.Image,
+		Args:           splitCommand(service.Command),
+		Env:            newEnv(name, service.Environment, replacer),
+		ReadinessProbe: newProbe(service.Healthcheck, replacer),
+	}
+
+	ports, err := newPorts(service)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if container.Resources, err = newResources(service); err != nil {
+		return nil, nil, err
+	}
+
+	for _, port := range ports {
+		container.Ports = append(container.Ports, ContainerPort{ContainerPort: port.TargetPort})
+	}
+
+	var (
+		volumes []Volume
+		claims  []PersistentVolumeClaim
+	)
+
+	for _, volume := range service.Volumes {
+		source, target, _ := strings.Cut(volume, ":")
+
+		switch {
+		case isNamedVolume(c, source):
+			claims = append(claims, PersistentVolumeClaim{
+				Metadata: ObjectMeta{Name: ResourceName(source)},
+				Spec: PersistentVolumeClaimSpec{
+					AccessModes: []string{"ReadWriteOnce"},
+					Resources: ResourceRequirements{
+						Requests: map[string]string{"storage": s.storageSize},
+					},
+				},
+			})
+			container.VolumeMounts = append(container.VolumeMounts, VolumeMount{Name: ResourceName(source), MountPath: target})
+		case target == configMountPath:
+			volumes = append(volumes, Volume{Name: "config", Secret: &SecretVolumeSource{SecretName: configSecretName}})
+			container.VolumeMounts = append(container.VolumeMounts, VolumeMount{Name: "config", MountPath: target, ReadOnly: true})
+		default:
+			return nil, nil, fmt.Errorf("unsupported volume %s", volume)
+		}
+	}
+
+	// The shared memory size of compose becomes a memory backed /dev/shm, ulimits have no equivalent in Kubernetes
+	if service.ShmSize != "" {
+		size, err := newQuantity(service.ShmSize)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		volumes = append(volumes, Volume{Name: "shm", EmptyDir: &EmptyDirVolumeSource{Medium: "Memory", SizeLimit: size}})
+		container.VolumeMounts = append(container.VolumeMounts, VolumeMount{Name: "shm", MountPath: "/dev/shm"})
+	}
+
+	template := PodTemplateSpec{
+		Metadata: ObjectMeta{Labels: selectorLabels(name)},
+		Spec: PodSpec{
+			Containers: []Container{container},
+			Volumes:    volumes,
+		},
+	}
+
+	// Services owning persistent data are deployed as StatefulSets
+	if len(claims) > 0 {
+		return StatefulSet{
+			TypeMeta: TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+			Metadata: s.objectMeta(name, name),
+			Spec: StatefulSetSpec{
+				Replicas:             1,
+				ServiceName:          name,
+				Selector:             LabelSelector{MatchLabels: selectorLabels(name)},
+				Template:             template,
+				VolumeClaimTemplates: claims,
+			},
+		}, ports, nil
+	}
+
+	return Deployment{
+		TypeMeta: TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		Metadata: s.objectMeta(name, name),
+		Spec: DeploymentSpec{
+			Replicas: 1,
+			Selector: LabelSelector{MatchLabels: selectorLabels(name)},
+			Template: template,
+		},
+	}, ports, nil
+}
+
+func (s *settings) objectMeta(name string, component string) ObjectMeta {
+	labels := map[string]string{
+		"app.kubernetes.io/part-of": "rss3-node",
+	}
+
+	if component != "" {
+		labels["app.kubernetes.io/name"] = component
+	}
+
+	return ObjectMeta{
+		Name:      name,
+		Namespace: s.namespace,
+		Labels:    labels,
+	}
+}
+
+func selectorLabels(name string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name": name,
+	}
+}
+
+// newPorts collects the exposed and published ports of a compose service.
+// A published port "host:container" is served on the host port and forwarded to the container port.
+func newPorts(service compose.Service) ([]ServicePort, error) {
+	ports := make([]ServicePort, 0, len(service.Expose)+len(service.Ports))
+
+	for _, expose := range service.Expose {
+		port, err := strconv.Atoi(expose)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exposed port %s, %w", expose, err)
+		}
+
+		ports = append(ports, ServicePort{Name: fmt.Sprintf("port-%d", port), Port: port, TargetPort: port})
+	}
+
+	published, err := service.PublishedPorts()
+	if err != nil {
+		return nil, err
+	}
+
+	// The host IP of a published port has no meaning in a cluster
+	for _, port := range published {
+		ports = append(ports, ServicePort{Name: fmt.Sprintf("port-%d", port.HostPort), Port: port.HostPort, TargetPort: port.ContainerPort})
+	}
+
+	return ports, nil
+}
+
+// newResources converts the resource limits and reservations of a compose service into container limits and requests
+func newResources(service compose.Service) (*ResourceRequirements, error) {
+	if service.Deploy == nil {
+		return nil, nil
+	}
+
+	limits, err := newResourceList(service.Deploy.Resources.Limits)
+	if err != nil {
+		return nil, err
+	}
+
+	requests, err := newResourceList(service.Deploy.Resources.Reservations)
+	if err != nil {
+		return nil, err
+	}
+
+	if limits == nil && requests == nil {
+		return nil, nil
+	}
+
+	return &ResourceRequirements{Limits: limits, Requests: requests}, nil
+}
+
+func newResourceList(limits *compose.ResourceLimits) (map[string]string, error) {
+	if limits == nil {
+		return nil, nil
+	}
+
+	list := make(map[string]string)
+
+	if limits.CPUs != 0 {
+		list["cpu"] = strconv.FormatFloat(limits.CPUs, 'f', -1, 64)
+	}
+
+	if limits.Memory != "" {
+		memory, err := newQuantity(limits.Memory)
+		if err != nil {
+			return nil, err
+		}
+
+		list["memory"] = memory
+	}
+
+	return list, nil
+}
+
+// newQuantity converts a compose byte size such as 512m into a Kubernetes quantity such as 512Mi
+func newQuantity(size string) (string, error) {
+	bytes, err := compose.ParseMemory(size)
+	if err != nil {
+		return "", err
+	}
+
+	for _, unit := range []struct {
+		suffix string
+		shift  int
+	}{{"Gi", 30}, {"Mi", 20}, {"Ki", 10}} {
+		if bytes != 0 && bytes%(1<<unit.shift) == 0 {
+			return fmt.Sprintf("%d%s", bytes>>unit.shift, unit.suffix), nil
+		}
+	}
+
+	return strconv.FormatInt(bytes, 10), nil
+}
+
+// newEnv converts the environment of a compose service, the credentials are read from the credentials Secret
+func newEnv(name string, environment map[string]string, replacer *strings.Replacer) []EnvVar {
+	keys := make([]string, 0, len(environment))
+	for key := range environment {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	env := make([]EnvVar, 0, len(keys))
+
+	for _, key := range keys {
+		if !isCredential(key, environment[key]) {
+			env = append(env, EnvVar{Name: key, Value: replacer.Replace(environment[key])})
+
+			continue
+		}
+
+		env = append(env, EnvVar{Name: key, ValueFrom: &EnvVarSource{
+			SecretKeyRef: &SecretKeySelector{Name: credentialsSecretName, Key: credentialKey(name, key)},
+		}})
+	}
+
+	return env
+}
+
+// isCredential reports whether an environment variable holds a credential, the paths of mounted secrets are not
+func isCredential(key string, value string) bool {
+	return secrets.IsSensitiveKey(key) && value != ""
+}
+
+// credentialKey is the key of an environment variable of a workload in the credentials Secret, e.g. rss3-node-redis.REDISCLI_AUTH
+func credentialKey(name string, key string) string {
+	return name + "." + key
+}
+
+// splitCommand splits a compose command into arguments the way a shell does, keeping quoted arguments together.
+// Kubernetes also reduces $$ to $, like the interpolation of compose.
+func splitCommand(command string) []string {
+	var (
+		args    []string
+		arg     strings.Builder
+		inArg   bool
+		quoting rune
+	)
+
+	for _, r := range command {
+		switch {
+		case quoting != 0 && r == quoting:
+			quoting = 0
+		case quoting != 0:
+			arg.WriteRune(r)
+		case r == '\'' || r == '"':
+			quoting, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+			}
+
+			inArg = false
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args
+}
+
+// newProbe translates a compose healthcheck into an exec readiness probe, reaching the other services by their Service names.
+func newProbe(healthcheck compose.Healthcheck, replacer *strings.Replacer) *Probe {
+	if len(healthcheck.Test) < 2 {
+		return nil
+	}
+
+	var command []string
+
+	switch healthcheck.Test[0] {
+	case "CMD":
+		for _, arg := range healthcheck.Test[1:] {
+			command = append(command, replacer.Replace(arg))
+		}
+	case "CMD-SHELL":
+		command = []string{"sh", "-c", replacer.Replace(strings.Join(healthcheck.Test[1:], " "))}
+	default:
+		return nil
+	}
+
+	return &Probe{
+		Exec:                &ExecAction{Command: command},
+		InitialDelaySeconds: seconds(healthcheck.StartPeriod),
+		PeriodSeconds:       seconds(healthcheck.Interval),
+		TimeoutSeconds:      seconds(healthcheck.Timeout),
+		FailureThreshold:    healthcheck.Retries,
+	}
+}
+
+func seconds(d time.Duration) int {
+	return int(d / time.Second)
+}
+
+func isNamedVolume(c *compose.Compose, name string) bool {
+	_, ok := c.Volumes[name]
+
+	return ok
+}
+
+// ResourceName converts a compose service name into a valid Kubernetes resource name.
+func ResourceName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+}
+
+// newNameReplacer rewrites references to compose service names, e.g. in connection URIs,
+// into the corresponding Kubernetes Service names.
+func newNameReplacer(c *compose.Compose) *strings.Replacer {
+	names := make([]string, 0, len(c.Services))
+	for name := range c.Services {
+		names = append(names, name)
+	}
+
+	// Longer names first so a name is never replaced by one of its prefixes
+	sort.Slice(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j])
+	})
+
+	pairs := make([]string, 0, len(names)*2)
+	for _, name := range names {
+		pairs = append(pairs, name, ResourceName(name))
+	}
+
+	return strings.NewReplacer(pairs...)
+}
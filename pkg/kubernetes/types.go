@@ -0,0 +1,158 @@
+package kubernetes
+
+// The types below are a minimal subset of the Kubernetes API objects,
+// just enough to describe the services generated by the compose package.
+
+type TypeMeta struct {
+	APIVersion string `yaml:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty"`
+}
+
+type ObjectMeta struct {
+	Name      string            `yaml:"name,omitempty"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+type LabelSelector struct {
+	MatchLabels map[string]string `yaml:"matchLabels"`
+}
+
+type Deployment struct {
+	TypeMeta `yaml:",inline"`
+	Metadata ObjectMeta     `yaml:"metadata"`
+	Spec     DeploymentSpec `yaml:"spec"`
+}
+
+type DeploymentSpec struct {
+	Replicas int             `yaml:"replicas"`
+	Selector LabelSelector   `yaml:"selector"`
+	Template PodTemplateSpec `yaml:"template"`
+}
+
+type StatefulSet struct {
+	TypeMeta `yaml:",inline"`
+	Metadata ObjectMeta      `yaml:"metadata"`
+	Spec     StatefulSetSpec `yaml:"spec"`
+}
+
+type StatefulSetSpec struct {
+	Replicas             int                     `yaml:"replicas"`
+	ServiceName          string                  `yaml:"serviceName"`
+	Selector             LabelSelector           `yaml:"selector"`
+	Template             PodTemplateSpec         `yaml:"template"`
+	VolumeClaimTemplates []PersistentVolumeClaim `yaml:"volumeClaimTemplates,omitempty"`
+}
+
+type PersistentVolumeClaim struct {
+	TypeMeta `yaml:",inline"`
+	Metadata ObjectMeta                `yaml:"metadata"`
+	Spec     PersistentVolumeClaimSpec `yaml:"spec"`
+}
+
+type PersistentVolumeClaimSpec struct {
+	AccessModes []string             `yaml:"accessModes"`
+	Resources   ResourceRequirements `yaml:"resources"`
+}
+
+type ResourceRequirements struct {
+	Limits   map[string]string `yaml:"limits,omitempty"`
+	Requests map[string]string `yaml:"requests,omitempty"`
+}
+
+type PodTemplateSpec struct {
+	Metadata ObjectMeta `yaml:"metadata"`
+	Spec     PodSpec    `yaml:"spec"`
+}
+
+type PodSpec struct {
+	Containers []Container `yaml:"containers"`
+	Volumes    []Volume    `yaml:"volumes,omitempty"`
+}
+
+type Container struct {
+	Name           string                `yaml:"name"`
+	Image          string                `yaml:"image"`
+	Args           []string              `yaml:"args,omitempty"`
+	Env            []EnvVar              `yaml:"env,omitempty"`
+	Ports          []ContainerPort       `yaml:"ports,omitempty"`
+	VolumeMounts   []VolumeMount         `yaml:"volumeMounts,omitempty"`
+	ReadinessProbe *Probe                `yaml:"readinessProbe,omitempty"`
+	Resources      *ResourceRequirements `yaml:"resources,omitempty"`
+}
+
+type EnvVar struct {
+	Name      string        `yaml:"name"`
+	Value     string        `yaml:"value,omitempty"`
+	ValueFrom *EnvVarSource `yaml:"valueFrom,omitempty"`
+}
+
+type EnvVarSource struct {
+	SecretKeyRef *SecretKeySelector `yaml:"secretKeyRef,omitempty"`
+}
+
+type SecretKeySelector struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+type ContainerPort struct {
+	ContainerPort int `yaml:"containerPort"`
+}
+
+type VolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+	ReadOnly  bool   `yaml:"readOnly,omitempty"`
+}
+
+type Volume struct {
+	Name     string                `yaml:"name"`
+	Secret   *SecretVolumeSource   `yaml:"secret,omitempty"`
+	EmptyDir *EmptyDirVolumeSource `yaml:"emptyDir,omitempty"`
+}
+
+type EmptyDirVolumeSource struct {
+	Medium    string `yaml:"medium,omitempty"`
+	SizeLimit string `yaml:"sizeLimit,omitempty"`
+}
+
+type SecretVolumeSource struct {
+	SecretName string `yaml:"secretName"`
+}
+
+type Probe struct {
+	Exec                *ExecAction `yaml:"exec,omitempty"`
+	InitialDelaySeconds int         `yaml:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       int         `yaml:"periodSeconds,omitempty"`
+	TimeoutSeconds      int         `yaml:"timeoutSeconds,omitempty"`
+	FailureThreshold    int         `yaml:"failureThreshold,omitempty"`
+}
+
+type ExecAction struct {
+	Command []string `yaml:"command"`
+}
+
+type Service struct {
+	TypeMeta `yaml:",inline"`
+	Metadata ObjectMeta  `yaml:"metadata"`
+	Spec     ServiceSpec `yaml:"spec"`
+}
+
+type ServiceSpec struct {
+	Selector map[string]string `yaml:"selector"`
+	Ports    []ServicePort     `yaml:"ports"`
+}
+
+type ServicePort struct {
+	Name       string `yaml:"name"`
+	Port       int    `yaml:"port"`
+	TargetPort int    `yaml:"targetPort"`
+}
+
+type Secret struct {
+	TypeMeta   `yaml:",inline"`
+	Metadata   ObjectMeta        `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	StringData map[string]string `yaml:"stringData"`
+}
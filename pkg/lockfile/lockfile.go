@@ -0,0 +1,89 @@
+package lockfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/fileutil"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Lockfile is the deployment plan resolved by a run of the deployer, the decisions depending on the network
+// or the host rather than on the config file. Later runs reuse it to generate the same compose file from the same config.
+type Lockfile struct {
+	AI AI `yaml:"ai"`
+	// NodeVersion is the node version requested by $NODE_VERSION or the version manifest, the node image is resolved from
+	NodeVersion string                   `yaml:"node_version"`
+	Images      map[string]compose.Image `yaml:"images"`
+	// Ports are the host ports published by the services by container port, reassigned ones included
+	Ports map[string]map[int]int `yaml:"ports,omitempty"`
+	// Secrets are fingerprints of the generated credentials, the credentials themselves stay in the secrets file
+	Secrets map[string]string `yaml:"secrets,omitempty"`
+}
+
+// AI is the AI component decided by a --ai mode for an endpoint of the config file
+type AI struct {
+	Mode      string `yaml:"mode"`
+	Component string `yaml:"component"`
+	Endpoint  string `yaml:"endpoint,omitempty"`
+}
+
+// Path returns the lockfile of a config file, next to it, e.g. config/config.lock.yaml for config/config.yaml
+func Path(configFile string) string {
+	extension := filepath.Ext(configFile)
+
+	return strings.TrimSuffix(configFile, extension) + ".lock" + extension
+}
+
+// Load reads the lockfile, a missing file returns nil
+func Load(file string) (*Lockfile, error) {
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read lockfile, %w", err)
+	}
+
+	var l Lockfile
+	if err := yaml.Unmarshal(content, &l); err != nil {
+		return nil, fmt.Errorf("parse lockfile %s, %w", file, err)
+	}
+
+	return &l, nil
+}
+
+// Save writes the lockfile, readable by the owner only as it holds the fingerprints of the credentials
+func (l *Lockfile) Save(file string) error {
+	var b bytes.Buffer
+
+	b.WriteString("# Generated by node-automated-deployer, pass --refresh to resolve it again\n")
+
+	e := yaml.NewEncoder(&b)
+	e.SetIndent(2)
+
+	if err := e.Encode(l); err != nil {
+		return fmt.Errorf("encode lockfile, %w", err)
+	}
+
+	if err := fileutil.WriteFile(file, b.Bytes(), 0600); err != nil {
+		return fmt.Errorf("write lockfile, %w", err)
+	}
+
+	return nil
+}
+
+// Fingerprint returns a short hash of a secret, enough to tell it changed without revealing it
+func Fingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,56 @@
+package lockfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/compose"
+	"github.com/rss3-network/node-automated-deployer/pkg/lockfile"
+)
+
+func TestSave(t *testing.T) {
+	t.Parallel()
+
+	file := lockfile.Path(filepath.Join(t.TempDir(), "config.yaml"))
+
+	// A lockfile written by an earlier version is readable by everyone
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	locked := &lockfile.Lockfile{
+		AI:          lockfile.AI{Mode: "auto", Component: "local", Endpoint: "http://agentdata:8887"},
+		NodeVersion: "v2.0.1",
+		Images:      map[string]compose.Image{"node": {Repository: "rss3/node", Tag: "v2.0.1", Digest: "sha256:abc"}},
+		Ports:       map[string]map[int]int{"rss3_node_core": {80: 8080}, "rss3_node_mastodon-core": {8181: 8182, 9090: 9091}},
+		Secrets:     map[string]string{"postgres_password": lockfile.Fingerprint("password")},
+	}
+
+	if err := locked.Save(file); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %o", info.Mode().Perm())
+	}
+
+	loaded, err := lockfile.Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(loaded, locked) {
+		t.Errorf("expected %+v, got %+v", locked, loaded)
+	}
+
+	if missing, err := lockfile.Load(file + ".missing"); missing != nil || err != nil {
+		t.Errorf("expected no lockfile, got %+v and %v", missing, err)
+	}
+}
@@ -0,0 +1,418 @@
+package postgres
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+func TestParseMajorVersion(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		version  string
+		expected int
+	}{
+		{version: "16.2 (Debian 16.2-1.pgdg120+2)", expected: 16},
+		{version: "15.10", expected: 15},
+		{version: "17", expected: 17},
+		{version: "9.6.24", expected: 9},
+		{version: "", expected: 0},
+	}
+
+	for _, testcase := range testcases {
+		if actual := parseMajorVersion(testcase.version); actual != testcase.expected {
+			t.Errorf("expected the major version of %q to be %d, got %d", testcase.version, testcase.expected, actual)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		server   fakeServer
+		userinfo string
+		expected Server
+		err      string
+	}{
+		{
+			name:     "trust",
+			server:   fakeServer{method: "trust", version: "16.2 (Debian 16.2-1.pgdg120+2)"},
+			userinfo: "postgres@",
+			expected: Server{Version: "16.2 (Debian 16.2-1.pgdg120+2)", MajorVersion: 16},
+		},
+		{
+			name:     "cleartext password",
+			server:   fakeServer{method: "password", password: "secret", version: "15.10"},
+			userinfo: "postgres:secret@",
+			expected: Server{Version: "15.10", MajorVersion: 15},
+		},
+		{
+			name:     "wrong cleartext password",
+			server:   fakeServer{method: "password", password: "secret", version: "15.10"},
+			userinfo: "postgres:wrong@",
+			err:      "28P01",
+		},
+		{
+			name:     "md5 password",
+			server:   fakeServer{method: "md5", password: "p@ss:w/rd", version: "14.1"},
+			userinfo: "postgres:p%40ss%3Aw%2Frd@",
+			expected: Server{Version: "14.1", MajorVersion: 14},
+		},
+		{
+			name:     "wrong md5 password",
+			server:   fakeServer{method: "md5", password: "secret", version: "14.1"},
+			userinfo: "postgres:wrong@",
+			err:      "28P01",
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			address := testcase.server.start(t)
+
+			server, err := Check(newContext(t), "postgres://"+testcase.userinfo+address+"/postgres?sslmode=disable")
+			if testcase.err != "" {
+				if err == nil || !strings.Contains(err.Error(), testcase.err) {
+					t.Fatalf("expected an error with %s, got %v", testcase.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *server != testcase.expected {
+				t.Errorf("expected %+v, got %+v", testcase.expected, *server)
+			}
+		})
+	}
+}
+
+func TestCheckDefaults(t *testing.T) {
+	t.Parallel()
+
+	sessions := make(chan session, 2)
+	address := (&fakeServer{method: "trust", version: "16.2", sessions: sessions}).start(t)
+
+	// The user defaults to postgres, the database to the user like in libpq
+	testcases := []struct {
+		uri      string
+		user     string
+		database string
+	}{
+		{uri: "postgresql://" + address + "?sslmode=disable", user: "postgres", database: "postgres"},
+		{uri: "postgresql://rss3@" + address + "?sslmode=disable", user: "rss3", database: "rss3"},
+	}
+
+	for _, testcase := range testcases {
+		if _, err := Check(newContext(t), testcase.uri); err != nil {
+			t.Fatal(err)
+		}
+
+		if parameters := (<-sessions).parameters; parameters["user"] != testcase.user || parameters["database"] != testcase.database {
+			t.Errorf("expected the user %s and the database %s, got %v", testcase.user, testcase.database, parameters)
+		}
+	}
+
+	for _, uri := range []string{"mysql://root@localhost", "postgres://%zz"} {
+		if _, err := Check(newContext(t), uri); err == nil {
+			t.Errorf("expected an error for %s", uri)
+		}
+	}
+}
+
+func TestCheckTLS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	trusted, trustedPEM := newCertificate(t, "db.internal")
+	_, otherPEM := newCertificate(t, "db.internal")
+
+	rootCert := filepath.Join(dir, "root.crt")
+	otherRootCert := filepath.Join(dir, "other.crt")
+
+	for file, content := range map[string][]byte{rootCert: trustedPEM, otherRootCert: otherPEM} {
+		if err := os.WriteFile(file, content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	serverTLS := &tls.Config{Certificates: []tls.Certificate{trusted}, MinVersion: tls.VersionTLS12}
+
+	// The server is reached by its IP address, which the certificate of db.internal doesn't cover
+	testcases := []struct {
+		name      string
+		query     string
+		serverTLS *tls.Config
+		expectTLS bool
+		expectErr bool
+	}{
+		{name: "disable", query: "sslmode=disable", serverTLS: serverTLS},
+		{name: "prefer without server tls", query: "sslmode=prefer"},
+		{name: "prefer", query: "sslmode=prefer", serverTLS: serverTLS, expectTLS: true},
+		{name: "require without server tls", query: "sslmode=require", expectErr: true},
+		{name: "require accepts any certificate", query: "sslmode=require", serverTLS: serverTLS, expectTLS: true},
+		{name: "verify-ca ignores the host name", query: "sslmode=verify-ca&sslrootcert=" + rootCert, serverTLS: serverTLS, expectTLS: true},
+		{name: "verify-ca with another root", query: "sslmode=verify-ca&sslrootcert=" + otherRootCert, serverTLS: serverTLS, expectErr: true},
+		{name: "verify-ca with the system roots", query: "sslmode=verify-ca", serverTLS: serverTLS, expectErr: true},
+		{name: "verify-full checks the host name", query: "sslmode=verify-full&sslrootcert=" + rootCert, serverTLS: serverTLS, expectErr: true},
+		{name: "missing sslrootcert", query: "sslmode=verify-ca&sslrootcert=" + filepath.Join(dir, "missing.crt"), serverTLS: serverTLS, expectErr: true},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			sessions := make(chan session, 2)
+			address := (&fakeServer{method: "trust", version: "16.2", tls: testcase.serverTLS, sessions: sessions}).start(t)
+
+			_, err := Check(newContext(t), "postgres://postgres@"+address+"/postgres?"+testcase.query)
+			if (err != nil) != testcase.expectErr {
+				t.Fatalf("expected an error %v, got %v", testcase.expectErr, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if s := <-sessions; s.tls != testcase.expectTLS {
+				t.Errorf("expected tls %v, got %v", testcase.expectTLS, s.tls)
+			}
+		})
+	}
+}
+
+// fakeServer is a scripted PostgreSQL server, answering every login with the authentication method
+type fakeServer struct {
+	// method is trust, password or md5
+	method   string
+	password string
+	version  string
+	// tls is used to accept an SSLRequest, which is refused if nil
+	tls *tls.Config
+	// sessions receives every successful login if set
+	sessions chan session
+}
+
+// session is a login accepted by the fake server
+type session struct {
+	parameters map[string]string
+	tls        bool
+}
+
+// start serves the logins until the end of the test, returning the address of the server
+func (s *fakeServer) start(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				_ = s.serve(conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func (s *fakeServer) serve(conn net.Conn) error {
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		return err
+	}
+
+	var current session
+
+	backend := pgproto3.NewBackend(conn, conn)
+
+	message, err := backend.ReceiveStartupMessage()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := message.(*pgproto3.SSLRequest); ok {
+		if s.tls == nil {
+			_, err := conn.Write([]byte{'N'})
+
+			return err
+		}
+
+		if _, err := conn.Write([]byte{'S'}); err != nil {
+			return err
+		}
+
+		tlsConn := tls.Server(conn, s.tls)
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+
+		current.tls = true
+		backend = pgproto3.NewBackend(tlsConn, tlsConn)
+
+		if message, err = backend.ReceiveStartupMessage(); err != nil {
+			return err
+		}
+	}
+
+	startup, ok := message.(*pgproto3.StartupMessage)
+	if !ok {
+		return fmt.Errorf("unexpected startup message %T", message)
+	}
+
+	current.parameters = startup.Parameters
+
+	if ok, err := s.authenticate(backend, startup.Parameters["user"]); err != nil || !ok {
+		if err == nil {
+			backend.Send(&pgproto3.ErrorResponse{Severity: "FATAL", Code: "28P01", Message: "password authentication failed"})
+			err = backend.Flush()
+		}
+
+		return err
+	}
+
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ParameterStatus{Name: "server_version", Value: s.version})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+
+	if err := backend.Flush(); err != nil {
+		return err
+	}
+
+	if s.sessions != nil {
+		s.sessions <- current
+	}
+
+	if message, err := backend.Receive(); err != nil {
+		return err
+	} else if _, ok := message.(*pgproto3.Terminate); !ok {
+		return fmt.Errorf("expected a terminate message, got %T", message)
+	}
+
+	return nil
+}
+
+func (s *fakeServer) authenticate(backend *pgproto3.Backend, user string) (bool, error) {
+	switch s.method {
+	case "trust":
+		return true, nil
+	case "password":
+		password, err := request(backend, &pgproto3.AuthenticationCleartextPassword{}, pgproto3.AuthTypeCleartextPassword)
+
+		return password == s.password, err
+	case "md5":
+		salt := [4]byte{1, 2, 3, 4}
+
+		password, err := request(backend, &pgproto3.AuthenticationMD5Password{Salt: salt}, pgproto3.AuthTypeMD5Password)
+
+		inner := md5.Sum([]byte(s.password + user))
+		outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt[:]...))
+
+		return password == "md5"+hex.EncodeToString(outer[:]), err
+	default:
+		return false, fmt.Errorf("unsupported authentication method %s", s.method)
+	}
+}
+
+// request sends an authentication request and returns the password answering it
+func request(backend *pgproto3.Backend, message pgproto3.BackendMessage, authType uint32) (string, error) {
+	backend.Send(message)
+
+	if err := backend.Flush(); err != nil {
+		return "", err
+	}
+
+	if err := backend.SetAuthType(authType); err != nil {
+		return "", err
+	}
+
+	answer, err := backend.Receive()
+	if err != nil {
+		return "", err
+	}
+
+	password, ok := answer.(*pgproto3.PasswordMessage)
+	if !ok {
+		return "", errors.New("expected a password message")
+	}
+
+	return password.Password, nil
+}
+
+func newContext(t *testing.T) context.Context {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	return ctx
+}
+
+// newCertificate returns a self-signed certificate for the host and its PEM encoding
+func newCertificate(t *testing.T, host string) (tls.Certificate, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
@@ -0,0 +1,259 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// manifestMediaTypes are the manifest formats accepted when resolving a digest, multi-platform indexes first
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+var (
+	// ErrTagNotFound is returned when a tag doesn't exist in the repository
+	ErrTagNotFound = errors.New("no such tag")
+	// ErrRepositoryNotFound is returned when the repository doesn't exist in the registry
+	ErrRepositoryNotFound = errors.New("no such repository")
+)
+
+// Client reads tags and manifests from the API of an OCI registry, with anonymous pull tokens when required
+type Client struct {
+	// BaseURL is the URL of the registry, e.g. https://ghcr.io
+	BaseURL string
+	Client  *http.Client
+
+	tokens map[string]string
+}
+
+// NewClient returns a Client of the registry at baseURL
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Client:  http.DefaultClient,
+		tokens:  make(map[string]string),
+	}
+}
+
+// dockerHub is the registry of the image references without a registry host, such as redis or google/alloydbomni
+const dockerHub = "https://registry-1.docker.io"
+
+// Locate splits an image repository such as ghcr.io/rss3-network/node into the URL of its registry and its path there.
+// Like docker, the first component is a registry host only if it has a dot, a port or is localhost,
+// the other repositories are on Docker Hub, the official images under library/.
+func Locate(repository string) (string, string) {
+	host, path, found := strings.Cut(repository, "/")
+	if found && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		return "https://" + host, path
+	}
+
+	if !found {
+		return dockerHub, "library/" + repository
+	}
+
+	return dockerHub, repository
+}
+
+// Tags returns all the tags of the repository, e.g. rss3-network/node
+func (c *Client) Tags(ctx context.Context, repository string) ([]string, error) {
+	var tags []string
+
+	next := fmt.Sprintf("%s/v2/%s/tags/list?n=1000", c.BaseURL, repository)
+
+	for next != "" {
+		response, err := c.do(ctx, http.MethodGet, next, repository, nil, ErrRepositoryNotFound)
+		if err != nil {
+			return nil, fmt.Errorf("list tags of %s, %w", repository, err)
+		}
+
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+
+		err = json.NewDecoder(response.Body).Decode(&body)
+		response.Body.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("decode tags of %s, %w", repository, err)
+		}
+
+		tags = append(tags, body.Tags...)
+
+		if next, err = c.nextPage(next, response.Header.Get("Link")); err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+// Digest returns the digest of the manifest the tag points to, the one pulled by docker
+func (c *Client) Digest(ctx context.Context, repository, tag string) (string, error) {
+	endpoint := fmt.Sprintf("%s/v2/%s/manifests/%s", c.BaseURL, repository, tag)
+	header := http.Header{"Accept": {strings.Join(manifestMediaTypes, ", ")}}
+
+	response, err := c.do(ctx, http.MethodHead, endpoint, repository, header, ErrTagNotFound)
+	if err != nil {
+		return "", fmt.Errorf("resolve digest of %s:%s, %w", repository, tag, err)
+	}
+	response.Body.Close()
+
+	if digest := response.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	// The header is optional, the digest is then the hash of the manifest itself
+	response, err = c.do(ctx, http.MethodGet, endpoint, repository, header, ErrTagNotFound)
+	if err != nil {
+		return "", fmt.Errorf("resolve digest of %s:%s, %w", repository, tag, err)
+	}
+	defer response.Body.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, response.Body); err != nil {
+		return "", fmt.Errorf("read manifest of %s:%s, %w", repository, tag, err)
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// do sends the request, with a pull token for the repository if the registry asks for one.
+// A missing resource returns notFound, the error of what the endpoint looks up.
+func (c *Client) do(ctx context.Context, method, endpoint, repository string, header http.Header, notFound error) (*http.Response, error) {
+	response, err := c.send(ctx, method, endpoint, c.tokens[repository], header)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusUnauthorized && c.tokens[repository] == "" {
+		challenge := response.Header.Get("WWW-Authenticate")
+		response.Body.Close()
+
+		token, err := c.token(ctx, challenge)
+		if err != nil {
+			return nil, err
+		}
+
+		c.tokens[repository] = token
+
+		if response, err = c.send(ctx, method, endpoint, token, header); err != nil {
+			return nil, err
+		}
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return response, nil
+	case http.StatusNotFound:
+		response.Body.Close()
+
+		return nil, notFound
+	default:
+		response.Body.Close()
+
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, endpoint, token string, header http.Header) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		request.Header[key] = values
+	}
+
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return c.Client.Do(request)
+}
+
+// challengeParameter matches the parameters of a WWW-Authenticate challenge, e.g. realm="https://ghcr.io/token"
+var challengeParameter = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// token requests an anonymous token from the authorization server of a Bearer challenge
+func (c *Client) token(ctx context.Context, challenge string) (string, error) {
+	scheme, parameters, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported registry authentication %q", challenge)
+	}
+
+	query := url.Values{}
+
+	var realm string
+
+	for _, match := range challengeParameter.FindAllStringSubmatch(parameters, -1) {
+		if match[1] == "realm" {
+			realm = match[2]
+		} else {
+			query.Set(match[1], match[2])
+		}
+	}
+
+	if realm == "" {
+		return "", fmt.Errorf("registry authentication %q has no realm", challenge)
+	}
+
+	response, err := c.send(ctx, http.MethodGet, realm+"?"+query.Encode(), "", nil)
+	if err != nil {
+		return "", fmt.Errorf("request registry token, %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("request registry token, unexpected status %s", response.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode registry token, %w", err)
+	}
+
+	if body.Token != "" {
+		return body.Token, nil
+	}
+
+	return body.AccessToken, nil
+}
+
+// nextPage returns the URL of the next page given by a Link header, e.g. </v2/x/tags/list?last=v1&n=1000>; rel="next"
+func (c *Client) nextPage(current, link string) (string, error) {
+	target, parameters, _ := strings.Cut(link, ";")
+	if !strings.Contains(parameters, `rel="next"`) {
+		return "", nil
+	}
+
+	target = strings.Trim(strings.TrimSpace(target), "<>")
+
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+
+	next, err := base.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid link header %q, %w", link, err)
+	}
+
+	return next.String(), nil
+}
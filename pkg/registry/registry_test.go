@@ -0,0 +1,277 @@
+package registry_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/registry"
+)
+
+const (
+	repository = "rss3-network/node"
+	token      = "pull-token"
+)
+
+// pages are the tags of the repository, one page of the tags list after the other
+var pages = [][]string{
+	{"v1.0.0", "v2.0.0", "v2.0.1"},
+	{"v2.0.2-beta", "v2.1.0", "v3.0.0-rc.1", "latest"},
+}
+
+// withoutDigestHeader are the tags whose manifest is served without the Docker-Content-Digest header
+var withoutDigestHeader = map[string]bool{"v2.0.1": true}
+
+// fakeRegistry is a registry requiring an anonymous pull token, it counts the token requests
+type fakeRegistry struct {
+	server *httptest.Server
+	tokens atomic.Int32
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	t.Helper()
+
+	r := new(fakeRegistry)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		r.tokens.Add(1)
+
+		if req.URL.Query().Get("scope") != "repository:"+repository+":pull" || req.URL.Query().Get("service") != "registry.test" {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": token})
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer "+token {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry.test",scope="repository:%s:pull"`, r.server.URL, repository))
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		prefix := "/v2/" + repository + "/"
+
+		switch path := req.URL.Path; {
+		case path == prefix+"tags/list":
+			r.tagsList(w, req)
+		case strings.HasPrefix(path, prefix+"manifests/"):
+			r.manifest(w, strings.TrimPrefix(path, prefix+"manifests/"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	r.server = httptest.NewServer(mux)
+	t.Cleanup(r.server.Close)
+
+	return r
+}
+
+func (r *fakeRegistry) tagsList(w http.ResponseWriter, req *http.Request) {
+	page := 0
+	if req.URL.Query().Get("last") != "" {
+		page = 1
+	}
+
+	if page+1 < len(pages) {
+		w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?last=%s&n=1000>; rel="next"`, repository, pages[page][len(pages[page])-1]))
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]any{"name": repository, "tags": pages[page]})
+}
+
+func (r *fakeRegistry) manifest(w http.ResponseWriter, tag string) {
+	for _, page := range pages {
+		for _, existing := range page {
+			if existing != tag {
+				continue
+			}
+
+			body := manifestBody(tag)
+
+			w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+
+			if !withoutDigestHeader[tag] {
+				w.Header().Set("Docker-Content-Digest", "sha256:header-"+tag)
+			}
+
+			_, _ = w.Write(body)
+
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func manifestBody(tag string) []byte {
+	return []byte(`{"schemaVersion":2,"tag":"` + tag + `"}`)
+}
+
+func hashedDigest(tag string) string {
+	sum := sha256.Sum256(manifestBody(tag))
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestTags(t *testing.T) {
+	t.Parallel()
+
+	r := newFakeRegistry(t)
+	client := registry.NewClient(r.server.URL + "/")
+
+	tags, err := client.Tags(context.Background(), repository)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := append(append([]string{}, pages[0]...), pages[1]...); !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected %v, got %v", expected, tags)
+	}
+
+	// The token of the first page is reused for the next one
+	if count := r.tokens.Load(); count != 1 {
+		t.Errorf("expected a single token request, got %d", count)
+	}
+
+	if _, err := client.Tags(context.Background(), "rss3-network/missing"); !errors.Is(err, registry.ErrRepositoryNotFound) {
+		t.Errorf("expected %v, got %v", registry.ErrRepositoryNotFound, err)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	t.Parallel()
+
+	r := newFakeRegistry(t)
+
+	testcases := []struct {
+		name     string
+		tag      string
+		expected string
+		err      error
+	}{
+		{name: "docker-content-digest header", tag: "v2.1.0", expected: "sha256:header-v2.1.0"},
+		{name: "hashed manifest", tag: "v2.0.1", expected: hashedDigest("v2.0.1")},
+		{name: "missing tag", tag: "v9.9.9", err: registry.ErrTagNotFound},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			digest, err := registry.NewClient(r.server.URL).Digest(context.Background(), repository, testcase.tag)
+			if testcase.err != nil {
+				if !errors.Is(err, testcase.err) {
+					t.Fatalf("expected %v, got %v", testcase.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if digest != testcase.expected {
+				t.Errorf("expected %s, got %s", testcase.expected, digest)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	r := newFakeRegistry(t)
+
+	testcases := []struct {
+		constraint string
+		tag        string
+		digest     string
+		err        error
+		expectErr  bool
+	}{
+		{constraint: "~2.0", tag: "v2.0.1", digest: hashedDigest("v2.0.1")},
+		{constraint: "~2", tag: "v2.1.0", digest: "sha256:header-v2.1.0"},
+		{constraint: "^2.0", tag: "v2.1.0", digest: "sha256:header-v2.1.0"},
+		{constraint: "^1", tag: "v1.0.0", digest: "sha256:header-v1.0.0"},
+		{constraint: registry.LatestStable, tag: "v2.1.0", digest: "sha256:header-v2.1.0"},
+		{constraint: "v3.0.0-rc.1", tag: "v3.0.0-rc.1", digest: "sha256:header-v3.0.0-rc.1"},
+		{constraint: "latest", tag: "latest", digest: "sha256:header-latest"},
+		{constraint: "~3.0", err: registry.ErrTagNotFound},
+		{constraint: "v9.9.9", err: registry.ErrTagNotFound},
+		{constraint: "~x", expectErr: true},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.constraint, func(t *testing.T) {
+			t.Parallel()
+
+			tag, digest, err := registry.NewClient(r.server.URL).Resolve(context.Background(), repository, testcase.constraint)
+			if testcase.err != nil || testcase.expectErr {
+				if err == nil || testcase.err != nil && !errors.Is(err, testcase.err) {
+					t.Fatalf("expected an error %v, got %v", testcase.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag != testcase.tag || digest != testcase.digest {
+				t.Errorf("expected %s@%s, got %s@%s", testcase.tag, testcase.digest, tag, digest)
+			}
+		})
+	}
+}
+
+func TestUnsupportedChallenge(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(server.Close)
+
+	if _, err := registry.NewClient(server.URL).Tags(context.Background(), repository); err == nil {
+		t.Error("expected an error for a basic authentication challenge")
+	}
+}
+
+func TestLocate(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		repository string
+		baseURL    string
+		path       string
+	}{
+		{repository: "ghcr.io/rss3-network/node", baseURL: "https://ghcr.io", path: "rss3-network/node"},
+		{repository: "localhost:5000/node", baseURL: "https://localhost:5000", path: "node"},
+		{repository: "localhost/node", baseURL: "https://localhost", path: "node"},
+		{repository: "google/alloydbomni", baseURL: "https://registry-1.docker.io", path: "google/alloydbomni"},
+		{repository: "redis", baseURL: "https://registry-1.docker.io", path: "library/redis"},
+	}
+
+	for _, testcase := range testcases {
+		if baseURL, path := registry.Locate(testcase.repository); baseURL != testcase.baseURL || path != testcase.path {
+			t.Errorf("expected %s to be %s on %s, got %s on %s", testcase.repository, testcase.path, testcase.baseURL, path, baseURL)
+		}
+	}
+}
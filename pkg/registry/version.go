@@ -0,0 +1,216 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LatestStable selects the newest tag that is a semantic version without pre-release
+const LatestStable = "latest-stable"
+
+// Version is a semantic version parsed from a tag such as v2.0.1 or 2.1.0-beta.1
+type Version struct {
+	Tag        string
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+// ParseVersion parses a tag as a semantic version, the v prefix and the patch number are optional
+func ParseVersion(tag string) (Version, bool) {
+	version := Version{Tag: tag}
+
+	core, prerelease, _ := strings.Cut(strings.TrimPrefix(tag, "v"), "-")
+	core, _, _ = strings.Cut(core, "+")
+	version.Prerelease = prerelease
+
+	parts := strings.Split(core, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return version, false
+	}
+
+	numbers := []*int{&version.Major, &version.Minor, &version.Patch}
+
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return version, false
+		}
+
+		*numbers[i] = n
+	}
+
+	return version, true
+}
+
+// Compare returns -1, 0 or 1 as v is older than, the same as or newer than other,
+// pre-releases are older than their release and compared as strings
+func (v Version) Compare(other Version) int {
+	for _, d := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	default:
+		return strings.Compare(v.Prerelease, other.Prerelease)
+	}
+}
+
+func sign(n int) int {
+	if n < 0 {
+		return -1
+	}
+
+	return 1
+}
+
+// Constraint selects a tag among the tags of a repository
+type Constraint struct {
+	raw string
+	// min and max bound the accepted versions, max excluded, for ~ and ^ ranges
+	min, max *Version
+}
+
+// ParseConstraint parses an exact tag, latest-stable, a tilde range such as ~2.0 (>=2.0.0 <2.1.0)
+// or a caret range such as ^2.0 (>=2.0.0 <3.0.0)
+func ParseConstraint(raw string) (Constraint, error) {
+	constraint := Constraint{raw: raw}
+
+	operator := raw[:min(len(raw), 1)]
+	if operator != "~" && operator != "^" {
+		return constraint, nil
+	}
+
+	// ~2 is the same range as ^2
+	rest := raw[1:]
+
+	partial := !strings.Contains(rest, ".")
+	if partial {
+		rest += ".0"
+	}
+
+	bound, ok := ParseVersion(rest)
+	if !ok {
+		return constraint, fmt.Errorf("invalid version constraint %s", raw)
+	}
+
+	upper := Version{Major: bound.Major + 1}
+	if operator == "~" && !partial {
+		upper = Version{Major: bound.Major, Minor: bound.Minor + 1}
+	}
+
+	bound.Prerelease = ""
+	constraint.min, constraint.max = &bound, &upper
+
+	return constraint, nil
+}
+
+// IsRange reports whether the constraint selects among several versions instead of naming a tag
+func (c Constraint) IsRange() bool {
+	return c.min != nil || c.raw == LatestStable
+}
+
+// Select returns the tag matching the constraint, the newest release for ranges
+func (c Constraint) Select(tags []string) (string, error) {
+	if !c.IsRange() {
+		for _, tag := range tags {
+			if tag == c.raw {
+				return tag, nil
+			}
+		}
+
+		return "", fmt.Errorf("%w%s", ErrTagNotFound, releasesHint(tags))
+	}
+
+	var selected *Version
+
+	for _, tag := range tags {
+		version, ok := ParseVersion(tag)
+		if !ok || version.Prerelease != "" {
+			continue
+		}
+
+		if c.min != nil && (version.Compare(*c.min) < 0 || version.Compare(*c.max) >= 0) {
+			continue
+		}
+
+		if selected == nil || version.Compare(*selected) > 0 {
+			selected = &version
+		}
+	}
+
+	if selected == nil {
+		return "", fmt.Errorf("%w in the range%s", ErrTagNotFound, releasesHint(tags))
+	}
+
+	return selected.Tag, nil
+}
+
+// releasesHint lists the newest releases in an error message, if there are any
+func releasesHint(tags []string) string {
+	releases := newestReleases(tags, 5)
+	if len(releases) == 0 {
+		return ""
+	}
+
+	return ", newest releases are " + strings.Join(releases, ", ")
+}
+
+// newestReleases returns the n newest tags which are semantic versions without pre-release
+func newestReleases(tags []string, n int) []string {
+	var versions []Version
+
+	for _, tag := range tags {
+		if version, ok := ParseVersion(tag); ok && version.Prerelease == "" {
+			versions = append(versions, version)
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Compare(versions[j]) > 0
+	})
+
+	releases := make([]string, 0, n)
+	for _, version := range versions[:min(n, len(versions))] {
+		releases = append(releases, version.Tag)
+	}
+
+	return releases
+}
+
+// Resolve returns the tag of the repository matching the constraint and its digest
+func (c *Client) Resolve(ctx context.Context, repository, raw string) (string, string, error) {
+	constraint, err := ParseConstraint(raw)
+	if err != nil {
+		return "", "", err
+	}
+
+	tags, err := c.Tags(ctx, repository)
+	if err != nil {
+		return "", "", err
+	}
+
+	tag, err := constraint.Select(tags)
+	if err != nil {
+		return "", "", fmt.Errorf("resolve %s of %s, %w", raw, repository, err)
+	}
+
+	digest, err := c.Digest(ctx, repository, tag)
+	if err != nil {
+		return "", "", err
+	}
+
+	return tag, digest, nil
+}
@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/fileutil"
+)
+
+// plainEnvValue matches the values written to an env file without quotes
+var plainEnvValue = regexp.MustCompile(`^[a-zA-Z0-9_./:@%+=-]*$`)
+
+// WriteEnvFile writes the variables to a .env file read by docker compose, readable by the owner only.
+// The other lines of an existing file are kept.
+func WriteEnvFile(file string, variables map[string]string) error {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var b strings.Builder
+
+	content, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read env file, %w", err)
+	}
+
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		name, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "export "), "=")
+		if _, ok := variables[strings.TrimSpace(name)]; ok || line == "" {
+			continue
+		}
+
+		b.WriteString(line)
+
+		if !strings.HasSuffix(line, "\n") {
+			b.WriteString("\n")
+		}
+	}
+
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%s\n", name, quoteEnvValue(variables[name]))
+	}
+
+	return fileutil.WriteFile(file, []byte(b.String()), 0600)
+}
+
+// WriteMountedFiles writes the files under root which are mounted into the containers. The containers don't run
+// as the owner of the files, so the files and the directories below root are readable by everyone,
+// while root itself is readable by the owner only to keep them from the other users of the host.
+func WriteMountedFiles(root string, files map[string]string) error {
+	if err := fileutil.MkdirAll(root, 0700); err != nil {
+		return err
+	}
+
+	for file, content := range files {
+		if dir := filepath.Dir(file); filepath.Clean(dir) != filepath.Clean(root) {
+			if err := fileutil.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+
+		if err := fileutil.WriteFile(file, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// quoteEnvValue single quotes values with special characters, docker compose keeps them literally
+func quoteEnvValue(value string) string {
+	if plainEnvValue.MatchString(value) {
+		return value
+	}
+
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'"
+	}
+
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(value) + `"`
+}
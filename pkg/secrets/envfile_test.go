@@ -0,0 +1,80 @@
+package secrets_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/secrets"
+)
+
+func TestWriteEnvFile(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), ".env")
+
+	// The lines of other variables are kept, an existing file loses its wider permissions
+	if err := os.WriteFile(file, []byte("COMPOSE_PROJECT_NAME=rss3\nREDISCLI_AUTH=old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := secrets.WriteEnvFile(file, map[string]string{"REDISCLI_AUTH": "new", "POSTGRES_PASSWORD": "it's $ecret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "COMPOSE_PROJECT_NAME=rss3\nPOSTGRES_PASSWORD=\"it's \\$ecret\"\nREDISCLI_AUTH=new\n"
+	if string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, content)
+	}
+
+	expectMode(t, file, 0600)
+}
+
+func TestWriteMountedFiles(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.Join(t.TempDir(), "rendered")
+	secretFile := filepath.Join(root, "rss3_node_alloydb_postgres_password")
+	configFile := filepath.Join(root, "config", "config.yaml")
+
+	// Files written before by the owner only are made readable by the containers
+	if err := os.MkdirAll(filepath.Join(root, "config"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(configFile, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := secrets.WriteMountedFiles(root, map[string]string{secretFile: "password", configFile: "database: {}\n"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The containers read the files as another user, the other users of the host can't reach them through root
+	expectMode(t, root, os.ModeDir|0700)
+	expectMode(t, filepath.Join(root, "config"), os.ModeDir|0755)
+	expectMode(t, secretFile, 0644)
+	expectMode(t, configFile, 0644)
+
+	if content, err := os.ReadFile(configFile); err != nil || string(content) != "database: {}\n" {
+		t.Errorf("expected the config file to be written, got %q, %v", content, err)
+	}
+}
+
+func expectMode(t *testing.T, file string, mode os.FileMode) {
+	t.Helper()
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode() != mode {
+		t.Errorf("expected %s to have the mode %s, got %s", file, mode, info.Mode())
+	}
+}
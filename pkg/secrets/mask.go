@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Masked is printed in place of a secret value
+const Masked = "******"
+
+// sensitiveKeyParts are the last segments of config and environment keys holding credentials
+var sensitiveKeyParts = [][]string{{"password"}, {"token"}, {"secret"}, {"api", "key"}, {"auth"}, {"connection"}}
+
+// IsSensitiveKey reports whether a config or environment key holds a credential, e.g. access_token or OPENAI_API_KEY.
+// Keys are split into segments on _, . and - and camel case, so author or connection_timeout are not sensitive.
+func IsSensitiveKey(key string) bool {
+	segments := keySegments(key)
+
+	for _, part := range sensitiveKeyParts {
+		if len(segments) >= len(part) && slices.Equal(segments[len(segments)-len(part):], part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keySegments splits a key into lower case segments, e.g. accessToken and ACCESS_TOKEN both become access and token
+func keySegments(key string) []string {
+	var (
+		segments []string
+		segment  strings.Builder
+		previous rune
+	)
+
+	for _, r := range key {
+		switch {
+		case r == '_' || r == '.' || r == '-':
+			segments = append(segments, segment.String())
+			segment.Reset()
+		case unicode.IsUpper(r) && unicode.IsLower(previous):
+			segments = append(segments, segment.String())
+			segment.Reset()
+
+			fallthrough
+		default:
+			segment.WriteRune(unicode.ToLower(r))
+		}
+
+		previous = r
+	}
+
+	return append(segments, segment.String())
+}
+
+// Masker hides known secret values in any text before it is printed
+type Masker struct {
+	replacer *strings.Replacer
+}
+
+// NewMasker returns a Masker hiding the given values, empty values are ignored
+func NewMasker(values ...string) *Masker {
+	// Replace longer values first, so a secret containing another one is hidden entirely
+	sorted := make([]string, 0, len(values))
+
+	for _, value := range values {
+		if value != "" {
+			sorted = append(sorted, value)
+		}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+
+	oldnew := make([]string, 0, 2*len(sorted))
+	for _, value := range sorted {
+		oldnew = append(oldnew, value, Masked)
+	}
+
+	return &Masker{replacer: strings.NewReplacer(oldnew...)}
+}
+
+// Mask returns the text with every secret value replaced
+func (m *Masker) Mask(text string) string {
+	return m.replacer.Replace(text)
+}
+
+// Values returns the secrets, to be hidden by a Masker
+func (s *Secrets) Values() []string {
+	return []string{s.PostgresPassword, s.RedisPassword}
+}
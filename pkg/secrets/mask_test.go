@@ -0,0 +1,54 @@
+package secrets_test
+
+import (
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/secrets"
+)
+
+func TestIsSensitiveKey(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		key      string
+		expected bool
+	}{
+		{key: "password", expected: true},
+		{key: "POSTGRES_PASSWORD", expected: true},
+		{key: "access_token", expected: true},
+		{key: "accessToken", expected: true},
+		{key: "OPENAI_API_KEY", expected: true},
+		{key: "openai.api-key", expected: true},
+		{key: "REDISCLI_AUTH", expected: true},
+		{key: "DB_CONNECTION", expected: true},
+		{key: "client_secret", expected: true},
+		{key: "author", expected: false},
+		{key: "connection_timeout", expected: false},
+		{key: "token_expiry", expected: false},
+		{key: "POSTGRES_PASSWORD_FILE", expected: false},
+		{key: "key", expected: false},
+		{key: "monkey", expected: false},
+		{key: "endpoint", expected: false},
+		{key: "", expected: false},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.key, func(t *testing.T) {
+			t.Parallel()
+
+			if actual := secrets.IsSensitiveKey(testcase.key); actual != testcase.expected {
+				t.Errorf("expected %v, got %v", testcase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMasker(t *testing.T) {
+	t.Parallel()
+
+	m := secrets.NewMasker("pass", "", "password123")
+
+	if actual, expected := m.Mask("uri: postgres://root:password123@db, redis: pass"), "uri: postgres://root:******@db, redis: ******"; actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
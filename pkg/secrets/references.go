@@ -0,0 +1,276 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// referencePattern matches secret references such as ${vault:secret/rss3#openai}, ${file:/run/secrets/x} or ${env:FOO}
+var referencePattern = regexp.MustCompile(`\$\{([a-z]+):([^}]+)\}`)
+
+// Resolver returns the value of a secret reference, given without its scheme
+type Resolver interface {
+	Resolve(ctx context.Context, reference string) (string, error)
+}
+
+// ResolverFunc adapts a function to the Resolver interface
+type ResolverFunc func(ctx context.Context, reference string) (string, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, reference string) (string, error) {
+	return f(ctx, reference)
+}
+
+// Interpolator replaces the secret references in config values with the values returned by the resolver of their scheme
+type Interpolator struct {
+	resolvers map[string]Resolver
+	resolved  []string
+}
+
+type InterpolatorOption func(*Interpolator)
+
+// WithResolver registers the resolver of a scheme, replacing the default one
+func WithResolver(scheme string, resolver Resolver) InterpolatorOption {
+	return func(i *Interpolator) {
+		i.resolvers[scheme] = resolver
+	}
+}
+
+// NewInterpolator returns an Interpolator resolving the env and file schemes, the other ones must be registered
+func NewInterpolator(options ...InterpolatorOption) *Interpolator {
+	i := &Interpolator{
+		resolvers: map[string]Resolver{
+			"env":  ResolverFunc(resolveEnv),
+			"file": ResolverFunc(resolveFile),
+		},
+	}
+
+	for _, option := range options {
+		option(i)
+	}
+
+	return i
+}
+
+// HasReferences reports whether the content contains secret references
+func HasReferences(content []byte) bool {
+	return referencePattern.Match(content)
+}
+
+// Interpolate returns the YAML content with the secret references of every scalar value resolved
+func (i *Interpolator) Interpolate(ctx context.Context, content []byte) ([]byte, error) {
+	if !HasReferences(content) {
+		return content, nil
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("parse config file, %w", err)
+	}
+
+	if err := i.interpolateNode(ctx, &document); err != nil {
+		return nil, err
+	}
+
+	resolved, err := yaml.Marshal(&document)
+	if err != nil {
+		return nil, fmt.Errorf("encode config file, %w", err)
+	}
+
+	return resolved, nil
+}
+
+// Values returns the values resolved so far, to be hidden by a Masker
+func (i *Interpolator) Values() []string {
+	return i.resolved
+}
+
+func (i *Interpolator) interpolateNode(ctx context.Context, node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		value, err := i.interpolateString(ctx, node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d, %w", node.Line, err)
+		}
+
+		if value != node.Value {
+			// Keep resolved values strings, a secret such as 0123 must not turn into a number
+			node.Value, node.Tag, node.Style = value, "!!str", 0
+		}
+
+		return nil
+	}
+
+	for _, child := range node.Content {
+		if err := i.interpolateNode(ctx, child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *Interpolator) interpolateString(ctx context.Context, value string) (string, error) {
+	var errs []error
+
+	interpolated := referencePattern.ReplaceAllStringFunc(value, func(match string) string {
+		groups := referencePattern.FindStringSubmatch(match)
+
+		resolver, ok := i.resolvers[groups[1]]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unsupported secret reference %s, no resolver for %s", match, groups[1]))
+
+			return match
+		}
+
+		resolved, err := resolver.Resolve(ctx, groups[2])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resolve secret reference %s, %w", match, err))
+
+			return match
+		}
+
+		i.resolved = append(i.resolved, resolved)
+
+		return resolved
+	})
+
+	return interpolated, errors.Join(errs...)
+}
+
+func resolveEnv(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	return value, nil
+}
+
+func resolveFile(_ context.Context, file string) (string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// splitVaultReference splits a reference such as secret/rss3#openai into the secret path and the key
+func splitVaultReference(reference string) (string, string, error) {
+	path, key, ok := strings.Cut(reference, "#")
+	if !ok || path == "" || key == "" {
+		return "", "", fmt.Errorf("invalid vault reference %s, must be path#key", reference)
+	}
+
+	return path, key, nil
+}
+
+// VaultResolver reads secrets from the KV version 2 engine of a HashiCorp Vault server,
+// the reference secret/rss3#openai is the key openai of the secret rss3 in the engine mounted at secret
+type VaultResolver struct {
+	Address string
+	Token   string
+	Client  *http.Client
+}
+
+// NewVaultResolverFromEnv returns a VaultResolver configured by VAULT_ADDR and VAULT_TOKEN like the vault CLI
+func NewVaultResolverFromEnv() *VaultResolver {
+	return &VaultResolver{
+		Address: os.Getenv("VAULT_ADDR"),
+		Token:   os.Getenv("VAULT_TOKEN"),
+		Client:  http.DefaultClient,
+	}
+}
+
+func (r *VaultResolver) Resolve(ctx context.Context, reference string) (string, error) {
+	path, key, err := splitVaultReference(reference)
+	if err != nil {
+		return "", err
+	}
+
+	if r.Address == "" {
+		return "", errors.New("vault address is not set, set VAULT_ADDR")
+	}
+
+	mount, name, ok := strings.Cut(path, "/")
+	if !ok {
+		return "", fmt.Errorf("invalid vault path %s, must be mount/name", path)
+	}
+
+	endpoint, err := url.JoinPath(r.Address, "v1", mount, "data", name)
+	if err != nil {
+		return "", fmt.Errorf("invalid vault address %s, %w", r.Address, err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+
+	request.Header.Set("X-Vault-Token", r.Token)
+
+	response, err := r.Client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("read vault secret %s, %w", path, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("read vault secret %s, unexpected status %s", path, response.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode vault secret %s, %w", path, err)
+	}
+
+	value, ok := body.Data.Data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in vault secret %s", key, path)
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+// FileStoreResolver is a local stand-in for Vault, reading path#key references from a YAML file
+// mapping every secret path to its keys, e.g. {secret/rss3: {openai: sk-...}}
+type FileStoreResolver struct {
+	File string
+}
+
+func (r *FileStoreResolver) Resolve(_ context.Context, reference string) (string, error) {
+	path, key, err := splitVaultReference(reference)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(r.File)
+	if err != nil {
+		return "", fmt.Errorf("read secret store, %w", err)
+	}
+
+	var store map[string]map[string]string
+	if err := yaml.Unmarshal(content, &store); err != nil {
+		return "", fmt.Errorf("parse secret store %s, %w", r.File, err)
+	}
+
+	value, ok := store[path][key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in secret %s of %s", key, path, r.File)
+	}
+
+	return value, nil
+}
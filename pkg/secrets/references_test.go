@@ -0,0 +1,181 @@
+package secrets_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/secrets"
+)
+
+//nolint:paralleltest // t.Setenv sets the variables read by the env references
+func TestInterpolate(t *testing.T) {
+	dir := t.TempDir()
+
+	secretFile := filepath.Join(dir, "kaito")
+	if err := os.WriteFile(secretFile, []byte("kaito-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := filepath.Join(dir, "vault.yaml")
+	if err := os.WriteFile(store, []byte("secret/rss3:\n  openai: sk-openai\n  pin: \"0123\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("OLLAMA_HOST", "ollama.local")
+	t.Setenv("SECRETS_TEST_UNSET", "")
+	os.Unsetenv("SECRETS_TEST_UNSET")
+
+	testcases := []struct {
+		name      string
+		content   string
+		expected  string
+		values    []string
+		expectErr bool
+	}{
+		{
+			name:     "no references",
+			content:  "a: 1 # kept as is\n",
+			expected: "a: 1 # kept as is\n",
+		},
+		{
+			name:     "env",
+			content:  "host: http://${env:OLLAMA_HOST}:11434\n",
+			expected: "host: http://ollama.local:11434\n",
+			values:   []string{"ollama.local"},
+		},
+		{
+			name:     "file",
+			content:  "token: ${file:" + secretFile + "}\n",
+			expected: "token: kaito-token\n",
+			values:   []string{"kaito-token"},
+		},
+		{
+			name:     "vault",
+			content:  "ai:\n  key: ${vault:secret/rss3#openai}\n  pin: ${vault:secret/rss3#pin}\n",
+			expected: "ai:\n    key: sk-openai\n    pin: \"0123\"\n",
+			values:   []string{"sk-openai", "0123"},
+		},
+		{
+			name:      "unknown scheme",
+			content:   "key: ${aws:secret}\n",
+			expectErr: true,
+		},
+		{
+			name:      "missing environment variable",
+			content:   "key: ${env:SECRETS_TEST_UNSET}\n",
+			expectErr: true,
+		},
+		{
+			name:      "missing file",
+			content:   "key: ${file:" + filepath.Join(dir, "missing") + "}\n",
+			expectErr: true,
+		},
+		{
+			name:      "missing vault key",
+			content:   "key: ${vault:secret/rss3#anthropic}\n",
+			expectErr: true,
+		},
+		{
+			name:      "missing vault secret",
+			content:   "key: ${vault:secret/other#openai}\n",
+			expectErr: true,
+		},
+		{
+			name:      "vault reference without key",
+			content:   "key: ${vault:secret/rss3}\n",
+			expectErr: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			interpolator := secrets.NewInterpolator(secrets.WithResolver("vault", &secrets.FileStoreResolver{File: store}))
+
+			resolved, err := interpolator.Interpolate(context.Background(), []byte(testcase.content))
+			if testcase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", resolved)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(resolved) != testcase.expected {
+				t.Errorf("expected %q, got %q", testcase.expected, resolved)
+			}
+
+			if !reflect.DeepEqual(interpolator.Values(), testcase.values) {
+				t.Errorf("expected the values %q, got %q", testcase.values, interpolator.Values())
+			}
+		})
+	}
+}
+
+func TestVaultResolver(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		if r.URL.Path != "/v1/secret/data/rss3" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"data":{"data":{"openai":"sk-openai"}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	testcases := []struct {
+		name      string
+		token     string
+		reference string
+		expected  string
+		expectErr bool
+	}{
+		{name: "key", token: "root", reference: "secret/rss3#openai", expected: "sk-openai"},
+		{name: "missing key", token: "root", reference: "secret/rss3#anthropic", expectErr: true},
+		{name: "missing secret", token: "root", reference: "secret/other#openai", expectErr: true},
+		{name: "forbidden", token: "wrong", reference: "secret/rss3#openai", expectErr: true},
+		{name: "path without mount", token: "root", reference: "rss3#openai", expectErr: true},
+	}
+
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			resolver := &secrets.VaultResolver{Address: server.URL, Token: testcase.token, Client: server.Client()}
+
+			value, err := resolver.Resolve(context.Background(), testcase.reference)
+			if testcase.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", value)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if value != testcase.expected {
+				t.Errorf("expected %q, got %q", testcase.expected, value)
+			}
+		})
+	}
+}
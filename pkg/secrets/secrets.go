@@ -0,0 +1,61 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/fileutil"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Secrets are the credentials generated for the services of a deployment.
+// They are kept in a local file so later runs reuse them instead of locking the services out of their data.
+type Secrets struct {
+	PostgresPassword string `yaml:"postgres_password"`
+	RedisPassword    string `yaml:"redis_password"`
+}
+
+// Load reads the secrets file, a missing file returns empty secrets
+func Load(file string) (*Secrets, error) {
+	var s Secrets
+
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return &s, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read secrets file, %w", err)
+	}
+
+	if err := yaml.Unmarshal(content, &s); err != nil {
+		return nil, fmt.Errorf("parse secrets file %s, %w", file, err)
+	}
+
+	return &s, nil
+}
+
+// Generate fills the missing secrets with values returned by generate and reports whether any was missing
+func (s *Secrets) Generate(generate func() string) bool {
+	var generated bool
+
+	for _, secret := range []*string{&s.PostgresPassword, &s.RedisPassword} {
+		if *secret == "" {
+			*secret = generate()
+			generated = true
+		}
+	}
+
+	return generated
+}
+
+// Save writes the secrets file, readable by the owner only
+func (s *Secrets) Save(file string) error {
+	content, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("encode secrets file, %w", err)
+	}
+
+	return fileutil.WriteFile(file, content, 0600)
+}
@@ -0,0 +1,341 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rss3-network/node-automated-deployer/pkg/fileutil"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// manifestFile lists the original location of every file in a snapshot
+const manifestFile = "snapshot.yaml"
+
+// filesDir is the directory of a snapshot holding the copied files, under their path relative to the working directory
+const filesDir = "files"
+
+// Snapshot is a versioned copy of the deployment files, taken before they are changed
+type Snapshot struct {
+	Dir   string            `yaml:"-"`
+	Time  time.Time         `yaml:"time"`
+	Files map[string]string `yaml:"files"`
+	// Dirs are the directories copied as a whole, the files added to them later are removed on restore
+	Dirs []string `yaml:"dirs,omitempty"`
+	// Modes are the permissions of the files, they are restored with them
+	Modes map[string]fs.FileMode `yaml:"modes,omitempty"`
+	// held are the files kept in memory only, nil for the paths which didn't exist
+	held     map[string][]byte
+	heldDirs []string
+}
+
+// Create copies the files and directories into a new snapshot directory under root, named after the current time.
+// Files that don't exist yet are skipped, they are removed again on restore.
+func Create(root string, paths ...string) (*Snapshot, error) {
+	now := time.Now().UTC()
+
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, fmt.Errorf("create snapshot, %w", err)
+	}
+
+	// The random suffix keeps snapshots taken within the same second apart
+	dir, err := os.MkdirTemp(root, now.Format("20060102T150405Z")+"-")
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot, %w", err)
+	}
+
+	s := &Snapshot{
+		Dir:   dir,
+		Time:  now,
+		Files: make(map[string]string, len(paths)),
+		Modes: make(map[string]fs.FileMode, len(paths)),
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			s.Files[path], err = "", nil
+		case err != nil:
+			return nil, fmt.Errorf("create snapshot, %w", err)
+		case info.IsDir():
+			err = s.addDir(path)
+		default:
+			err = s.addFile(path)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	manifest, err := yaml.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot, encode manifest, %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(s.Dir, manifestFile), manifest, 0600); err != nil {
+		return nil, fmt.Errorf("create snapshot, write manifest, %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *Snapshot) addDir(dir string) error {
+	s.Dirs = append(s.Dirs, dir)
+
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		return s.addFile(path)
+	})
+}
+
+func (s *Snapshot) addFile(file string) error {
+	content, mode, err := readFile(file)
+	if err != nil {
+		return fmt.Errorf("create snapshot, %w", err)
+	}
+
+	name, err := snapshotName(file)
+	if err != nil {
+		return fmt.Errorf("create snapshot, %w", err)
+	}
+
+	copied := filepath.Join(s.Dir, name)
+
+	if err := os.MkdirAll(filepath.Dir(copied), 0700); err != nil {
+		return fmt.Errorf("create snapshot, %w", err)
+	}
+
+	if err := os.WriteFile(copied, content, 0600); err != nil {
+		return fmt.Errorf("create snapshot, write %s, %w", name, err)
+	}
+
+	s.Files[file] = name
+	s.Modes[file] = mode
+
+	return nil
+}
+
+// readFile returns the content and the permissions of a file
+func readFile(file string) ([]byte, fs.FileMode, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return content, info.Mode().Perm(), nil
+}
+
+// Hold keeps the files and directories in memory only rather than in the snapshot directory,
+// for files holding plaintext credentials. They are restored like the other files, as long as the process runs.
+func (s *Snapshot) Hold(paths ...string) error {
+	if s.held == nil {
+		s.held = make(map[string][]byte)
+	}
+
+	if s.Modes == nil {
+		s.Modes = make(map[string]fs.FileMode)
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			s.held[path] = nil
+		case err != nil:
+			return fmt.Errorf("hold %s, %w", path, err)
+		case info.IsDir():
+			s.heldDirs = append(s.heldDirs, path)
+
+			err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+				if err != nil || entry.IsDir() {
+					return err
+				}
+
+				s.held[file], s.Modes[file], err = readFile(file)
+
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("hold %s, %w", path, err)
+			}
+		default:
+			if s.held[path], s.Modes[path], err = readFile(path); err != nil {
+				return fmt.Errorf("hold %s, %w", path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// snapshotName returns where a file is copied in the snapshot, its path relative to the working directory
+// so files of the same name in different directories don't collide, or its absolute path outside of it
+func snapshotName(file string) (string, error) {
+	absolute, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	relative, err := filepath.Rel(workingDir, absolute)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return filepath.Join(filesDir, "absolute", strings.TrimPrefix(absolute, filepath.VolumeName(absolute))), nil
+	}
+
+	return filepath.Join(filesDir, relative), nil
+}
+
+// Restore puts every file of the snapshot back to its original location
+func (s *Snapshot) Restore() error {
+	for _, dir := range append(s.Dirs, s.heldDirs...) {
+		if err := s.removeAdded(dir); err != nil {
+			return err
+		}
+	}
+
+	for file, content := range s.held {
+		if err := restoreFile(file, content, s.Modes[file]); err != nil {
+			return err
+		}
+	}
+
+	for file, name := range s.Files {
+		var content []byte
+
+		if name != "" {
+			var err error
+
+			if content, err = os.ReadFile(filepath.Join(s.Dir, name)); err != nil {
+				return fmt.Errorf("restore snapshot, read %s, %w", name, err)
+			}
+		}
+
+		if err := restoreFile(file, content, s.Modes[file]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreFile writes the content back to the file with its permissions, or removes it if the content is nil
+func restoreFile(file string, content []byte, mode fs.FileMode) error {
+	if content == nil {
+		if err := os.RemoveAll(file); err != nil {
+			return fmt.Errorf("restore snapshot, remove %s, %w", file, err)
+		}
+
+		return nil
+	}
+
+	// Files of a snapshot without their permissions are restored for the owner only
+	if mode == 0 {
+		mode = 0600
+	}
+
+	if err := fileutil.WriteFile(file, content, mode); err != nil {
+		return fmt.Errorf("restore snapshot, %w", err)
+	}
+
+	return nil
+}
+
+// removeAdded removes the files added to a directory since the snapshot
+func (s *Snapshot) removeAdded(dir string) error {
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		_, snapshotted := s.Files[path]
+		_, held := s.held[path]
+
+		if !snapshotted && !held {
+			return os.Remove(path)
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("restore snapshot, %w", err)
+	}
+
+	return nil
+}
+
+// Discard removes the snapshot directory, for a snapshot which turned out not to be needed
+func (s *Snapshot) Discard() error {
+	if err := os.RemoveAll(s.Dir); err != nil {
+		return fmt.Errorf("discard snapshot, %w", err)
+	}
+
+	return nil
+}
+
+// Prune removes the oldest snapshots under root, keeping the given number of the latest ones
+func Prune(root string, keep int) error {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("prune snapshots, %w", err)
+	}
+
+	var snapshots []*Snapshot
+
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(root, entry.Name(), manifestFile))
+		if !entry.IsDir() || errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("prune snapshots, %w", err)
+		}
+
+		s := Snapshot{Dir: filepath.Join(root, entry.Name())}
+		if err := yaml.Unmarshal(content, &s); err != nil {
+			return fmt.Errorf("prune snapshots, read manifest of %s, %w", s.Dir, err)
+		}
+
+		snapshots = append(snapshots, &s)
+	}
+
+	// The directory names only have a precision of a second, the manifests order the snapshots taken within the same one
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+
+	for len(snapshots) > keep {
+		if err := snapshots[0].Discard(); err != nil {
+			return err
+		}
+
+		snapshots = snapshots[1:]
+	}
+
+	return nil
+}
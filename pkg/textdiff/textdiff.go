@@ -0,0 +1,151 @@
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around every change
+const contextLines = 3
+
+type operation struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// Unified returns the differences between two texts in the unified diff format,
+// or an empty string if they are equal
+func Unified(oldName, newName string, old, new []byte) string {
+	operations := diffLines(splitLines(string(old)), splitLines(string(new)))
+
+	var b strings.Builder
+
+	for start := 0; start < len(operations); {
+		// Find the next change
+		for start < len(operations) && operations[start].kind == ' ' {
+			start++
+		}
+
+		if start == len(operations) {
+			break
+		}
+
+		// Extend the hunk until there are more unchanged lines than twice the context
+		end := start
+
+		for unchanged := 0; end < len(operations) && unchanged <= 2*contextLines; end++ {
+			if operations[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+
+		hunkStart := max(start-contextLines, 0)
+		hunkEnd := end
+
+		for hunkEnd > start && operations[hunkEnd-1].kind == ' ' {
+			hunkEnd--
+		}
+
+		hunkEnd = min(hunkEnd+contextLines, len(operations))
+
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+		}
+
+		writeHunk(&b, operations, hunkStart, hunkEnd)
+
+		start = hunkEnd
+	}
+
+	return b.String()
+}
+
+func writeHunk(b *strings.Builder, operations []operation, start, end int) {
+	// Line numbers of the hunk start in both texts
+	oldLine, newLine := 1, 1
+
+	for _, op := range operations[:start] {
+		if op.kind != '+' {
+			oldLine++
+		}
+
+		if op.kind != '-' {
+			newLine++
+		}
+	}
+
+	var oldCount, newCount int
+
+	for _, op := range operations[start:end] {
+		if op.kind != '+' {
+			oldCount++
+		}
+
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+
+	for _, op := range operations[start:end] {
+		fmt.Fprintf(b, "%c%s\n", op.kind, op.line)
+	}
+}
+
+// diffLines computes the line operations turning old into new from their longest common subsequence
+func diffLines(old, new []string) []operation {
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	operations := make([]operation, 0, len(old)+len(new))
+
+	i, j := 0, 0
+
+	for i < len(old) && j < len(new) {
+		switch {
+		case old[i] == new[j]:
+			operations = append(operations, operation{' ', old[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			operations = append(operations, operation{'-', old[i]})
+			i++
+		default:
+			operations = append(operations, operation{'+', new[j]})
+			j++
+		}
+	}
+
+	for ; i < len(old); i++ {
+		operations = append(operations, operation{'-', old[i]})
+	}
+
+	for ; j < len(new); j++ {
+		operations = append(operations, operation{'+', new[j]})
+	}
+
+	return operations
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}